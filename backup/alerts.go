@@ -0,0 +1,791 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ================= ALERTING =================
+// Rule trong alerts.json được đánh giá định kỳ trên các worker đang chạy. Mỗi cặp (rule, station)
+// có 1 trạng thái: điều kiện đúng liên tục for_s giây thì "firing" (gửi webhook 1 lần, gửi lại mỗi
+// repeat_s nếu có), điều kiện hết thì "resolved". Webhook là POST JSON chung (AlertNotification),
+// gửi ở goroutine riêng, có retry; kết quả gần nhất xem qua GET /api/alerts.
+const (
+	AlertsFile           = "alerts.json"
+	DefaultAlertInterval = 10   // Giây giữa 2 lần đánh giá rule
+	DefaultAlertWindow   = 3600 // Cửa sổ đếm mặc định (giây) của reconnects / crc_errors
+	WebhookTimeout       = 10 * time.Second
+	WebhookRetries       = 3
+	MaxWebhookDeliveries = 50        // Số lần gửi gần nhất giữ lại cho API
+	MaxAlertWindow       = 24 * 3600 // window_s lớn nhất (giây)
+)
+
+// Metric của rule. Giá trị > threshold là điều kiện báo động
+const (
+	MetricNotRunning = "not_running" // Số giây station không ở trạng thái Running
+	MetricNoData     = "no_data"     // Số giây từ frame RTCM hợp lệ cuối nhận từ source
+	MetricReconnects = "reconnects"  // Số session kết thúc do lỗi trong window_s giây
+	MetricCRCErrors  = "crc_errors"  // Số frame sai CRC trong window_s giây
+)
+
+var alertMetrics = map[string]bool{MetricNotRunning: true, MetricNoData: true, MetricReconnects: true, MetricCRCErrors: true}
+
+type Webhook struct {
+	Name    string            `json:"name"`
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"` // VD: Authorization, X-Api-Key
+}
+
+type AlertRule struct {
+	ID        string   `json:"id"`
+	Station   string   `json:"station,omitempty"` // "" hoặc "*" = mọi station
+	Metric    string   `json:"metric"`
+	Threshold float64  `json:"threshold"`          // Báo khi giá trị > threshold
+	ForS      int      `json:"for_s,omitempty"`    // Điều kiện phải đúng liên tục bấy nhiêu giây
+	WindowS   int      `json:"window_s,omitempty"` // reconnects/crc_errors: cửa sổ đếm (mặc định 3600)
+	RepeatS   int      `json:"repeat_s,omitempty"` // Còn firing thì gửi lại sau bấy nhiêu giây (0 = không)
+	Webhooks  []string `json:"webhooks,omitempty"` // Tên webhook nhận, rỗng = tất cả
+	Disabled  bool     `json:"disabled,omitempty"`
+}
+
+type AlertConfig struct {
+	IntervalS int         `json:"interval_s,omitempty"`
+	Webhooks  []Webhook   `json:"webhooks"`
+	Rules     []AlertRule `json:"rules"`
+}
+
+func (r *AlertRule) matches(station string) bool {
+	return r.Station == "" || r.Station == "*" || r.Station == station
+}
+
+func (r *AlertRule) window() time.Duration {
+	if r.WindowS > 0 {
+		return time.Duration(r.WindowS) * time.Second
+	}
+	return DefaultAlertWindow * time.Second
+}
+
+// validate - Kiểm tra toàn bộ config (ID rule không trùng, metric hợp lệ, webhook tồn tại, URL http/https)
+func (c *AlertConfig) validate() error {
+	if c.IntervalS < 0 {
+		return errors.New("interval_s must be >= 0")
+	}
+	hooks := make(map[string]bool)
+	for _, h := range c.Webhooks {
+		if h.Name == "" {
+			return errors.New("webhook name required")
+		}
+		if hooks[h.Name] {
+			return fmt.Errorf("duplicate webhook %q", h.Name)
+		}
+		hooks[h.Name] = true
+		if err := validateWebhookURL(h.URL); err != nil {
+			return fmt.Errorf("webhook %q: %w", h.Name, err)
+		}
+	}
+	ids := make(map[string]bool)
+	for _, r := range c.Rules {
+		if r.ID == "" {
+			return errors.New("rule id required")
+		}
+		if strings.Contains(r.ID, "/") {
+			return fmt.Errorf("rule %q: id must not contain '/'", r.ID)
+		}
+		if ids[r.ID] {
+			return fmt.Errorf("duplicate rule %q", r.ID)
+		}
+		ids[r.ID] = true
+		if !alertMetrics[r.Metric] {
+			return fmt.Errorf("rule %q: unknown metric %q", r.ID, r.Metric)
+		}
+		if r.Threshold < 0 || r.ForS < 0 || r.WindowS < 0 || r.RepeatS < 0 {
+			return fmt.Errorf("rule %q: threshold, for_s, window_s and repeat_s must be >= 0", r.ID)
+		}
+		if r.WindowS > MaxAlertWindow {
+			return fmt.Errorf("rule %q: window_s must be <= %d", r.ID, MaxAlertWindow)
+		}
+		for _, name := range r.Webhooks {
+			if !hooks[name] {
+				return fmt.Errorf("rule %q: unknown webhook %q", r.ID, name)
+			}
+		}
+	}
+	return nil
+}
+
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be http:// or https://")
+	}
+	return nil
+}
+
+func loadAlertConfig() AlertConfig {
+	var cfg AlertConfig
+	file, err := os.ReadFile(AlertsFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[Alerts] Read %s failed: %v", AlertsFile, err)
+		}
+		return cfg
+	}
+	if err := json.Unmarshal(file, &cfg); err != nil {
+		log.Printf("[Alerts] %s parse failed: %v. Alerting disabled", AlertsFile, err)
+		return AlertConfig{}
+	}
+	if err := cfg.validate(); err != nil {
+		log.Printf("[Alerts] %s invalid: %v. Alerting disabled", AlertsFile, err)
+		return AlertConfig{}
+	}
+	return cfg
+}
+
+// saveAlertConfig - Ghi file tạm rồi rename để không để lại alerts.json dở dang
+func saveAlertConfig(cfg AlertConfig) error {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := AlertsFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, AlertsFile)
+}
+
+// AlertNotification - Payload JSON gửi tới webhook
+type AlertNotification struct {
+	Status    string    `json:"status"` // firing | resolved | test
+	Rule      string    `json:"rule"`
+	Metric    string    `json:"metric"`
+	Station   string    `json:"station"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Message   string    `json:"message"`
+	StartedAt time.Time `json:"started_at"`       // Lúc bắt đầu firing
+	Time      time.Time `json:"time"`             // Lúc gửi
+	Repeat    bool      `json:"repeat,omitempty"` // Gửi lại do repeat_s
+	Relay     string    `json:"relay"`            // Hostname máy chạy relay
+}
+
+// ActiveAlert - Trạng thái của 1 cặp (rule, station) đang vượt ngưỡng
+type ActiveAlert struct {
+	Rule      string    `json:"rule"`
+	Station   string    `json:"station"`
+	Metric    string    `json:"metric"`
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Since     time.Time `json:"since"`             // Điều kiện bắt đầu đúng
+	Firing    bool      `json:"firing"`            // false = đang chờ đủ for_s
+	FiredAt   time.Time `json:"fired_at,omitzero"` // Lần báo đầu tiên
+	LastSent  time.Time `json:"last_sent,omitzero"`
+}
+
+type WebhookDelivery struct {
+	Time     time.Time `json:"time"`
+	Webhook  string    `json:"webhook"`
+	Status   string    `json:"status"` // Status của notification
+	Rule     string    `json:"rule"`
+	Station  string    `json:"station"`
+	Code     int       `json:"code,omitempty"` // HTTP status của lần thử cuối
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error,omitempty"`
+}
+
+type counterSample struct {
+	t time.Time
+	v int64
+}
+
+type alertEngine struct {
+	client   *http.Client
+	hostname string
+
+	mu         sync.Mutex
+	cfg        AlertConfig
+	active     map[string]*ActiveAlert     // rule + "\x00" + station
+	crc        map[*Worker][]counterSample // Bộ đếm CRC mỗi lần đánh giá (cho cửa sổ crc_errors)
+	deliveries []WebhookDelivery
+	reload     chan struct{} // Đổi interval_s
+}
+
+// alerts luôn có (config rỗng = không có rule), API dùng được ngay cả khi main chưa nạp alerts.json
+var alerts = newAlertEngine(AlertConfig{})
+
+func newAlertEngine(cfg AlertConfig) *alertEngine {
+	hostname, _ := os.Hostname()
+	return &alertEngine{
+		client:   &http.Client{Timeout: WebhookTimeout},
+		hostname: hostname,
+		cfg:      cfg,
+		active:   make(map[string]*ActiveAlert),
+		crc:      make(map[*Worker][]counterSample),
+		reload:   make(chan struct{}, 1),
+	}
+}
+
+func (e *alertEngine) interval() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.cfg.IntervalS > 0 {
+		return time.Duration(e.cfg.IntervalS) * time.Second
+	}
+	return DefaultAlertInterval * time.Second
+}
+
+func (e *alertEngine) run() {
+	ticker := time.NewTicker(e.interval())
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			e.evaluate(now)
+		case <-e.reload:
+			ticker.Reset(e.interval())
+		}
+	}
+}
+
+// setConfig - Áp dụng config mới. Trạng thái của rule còn giữ nguyên ID được giữ lại (không báo lại),
+// rule bị xoá/tắt thì bỏ trạng thái mà không gửi resolved
+func (e *alertEngine) setConfig(cfg AlertConfig) {
+	e.mu.Lock()
+	e.cfg = cfg
+	enabled := make(map[string]bool)
+	for _, r := range cfg.Rules {
+		if !r.Disabled {
+			enabled[r.ID] = true
+		}
+	}
+	for key, a := range e.active {
+		if !enabled[a.Rule] {
+			delete(e.active, key)
+		}
+	}
+	e.mu.Unlock()
+
+	select {
+	case e.reload <- struct{}{}:
+	default:
+	}
+}
+
+func (e *alertEngine) config() AlertConfig {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.cfg
+}
+
+// pendingNotification - Notification chờ gửi sau khi nhả khoá
+type pendingNotification struct {
+	n     AlertNotification
+	hooks []Webhook
+}
+
+// evaluate - Tính giá trị từng rule trên từng worker, chuyển trạng thái và gửi webhook
+func (e *alertEngine) evaluate(now time.Time) {
+	manager.mu.RLock()
+	workers := make([]*Worker, 0, len(manager.workers))
+	for _, worker := range manager.workers {
+		workers = append(workers, worker)
+	}
+	manager.mu.RUnlock()
+	sort.Slice(workers, func(i, j int) bool { return workers[i].cfg.ID < workers[j].cfg.ID })
+
+	// Đọc sự kiện (có thể đọc file) trước khi giữ e.mu, để API alert không phải chờ I/O
+	var events map[*Worker][]StationEvent
+	if cfg := e.config(); cfg.usesMetric(MetricReconnects) {
+		events = make(map[*Worker][]StationEvent, len(workers))
+		for _, worker := range workers {
+			events[worker] = stationEvents.list(worker.cfg.ID)
+		}
+	}
+
+	e.mu.Lock()
+	e.sampleCRC(workers, now)
+
+	var out []pendingNotification
+	seen := make(map[string]bool)
+	for i := range e.cfg.Rules {
+		rule := &e.cfg.Rules[i]
+		if rule.Disabled {
+			continue
+		}
+		for _, worker := range workers {
+			id := worker.cfg.ID
+			if !rule.matches(id) {
+				continue
+			}
+			key := rule.ID + "\x00" + id
+			seen[key] = true
+			evs, ok := events[worker]
+			if rule.Metric == MetricReconnects && !ok {
+				continue // Rule thêm sau lúc đọc sự kiện: giữ trạng thái, đánh giá ở lần sau
+			}
+			value, detail := e.value(rule, worker, evs, now)
+			a := e.active[key]
+
+			if value <= rule.Threshold {
+				if a != nil {
+					if a.Firing {
+						msg := fmt.Sprintf("%s: recovered after %s (%s)", id, formatAlertDuration(now.Sub(a.FiredAt)), detail)
+						out = append(out, e.notification(rule, a, "resolved", value, msg, now, false))
+					}
+					delete(e.active, key)
+				}
+				continue
+			}
+
+			if a == nil {
+				a = &ActiveAlert{Rule: rule.ID, Station: id, Metric: rule.Metric, Since: now}
+				e.active[key] = a
+			}
+			a.Value, a.Threshold = value, rule.Threshold
+			switch {
+			case !a.Firing && now.Sub(a.Since) >= time.Duration(rule.ForS)*time.Second:
+				a.Firing, a.FiredAt, a.LastSent = true, now, now
+				out = append(out, e.notification(rule, a, "firing", value, id+": "+detail, now, false))
+			case a.Firing && rule.RepeatS > 0 && now.Sub(a.LastSent) >= time.Duration(rule.RepeatS)*time.Second:
+				a.LastSent = now
+				out = append(out, e.notification(rule, a, "firing", value, id+": "+detail, now, true))
+			}
+		}
+	}
+
+	// Station đã bị xoá / dừng: alert đang firing coi như hết
+	for key, a := range e.active {
+		if seen[key] {
+			continue
+		}
+		if i := e.cfg.rule(a.Rule); a.Firing && i >= 0 {
+			msg := fmt.Sprintf("%s: station no longer running, alert cleared", a.Station)
+			out = append(out, e.notification(&e.cfg.Rules[i], a, "resolved", a.Value, msg, now, false))
+		}
+		delete(e.active, key)
+	}
+	e.mu.Unlock()
+
+	for _, p := range out {
+		log.Printf("[Alerts] %s %s: %s", strings.ToUpper(p.n.Status), p.n.Rule, p.n.Message)
+		for _, hook := range p.hooks {
+			go e.deliver(hook, p.n)
+		}
+	}
+}
+
+// ruleWebhooks - Webhook nhận notification của rule (rule không chỉ định = tất cả)
+func (c *AlertConfig) ruleWebhooks(rule *AlertRule) []Webhook {
+	var hooks []Webhook
+	for _, h := range c.Webhooks {
+		if len(rule.Webhooks) == 0 || containsString(rule.Webhooks, h.Name) {
+			hooks = append(hooks, h)
+		}
+	}
+	return hooks
+}
+
+// notification - Tạo payload và chọn webhook nhận theo rule (gọi khi giữ e.mu)
+func (e *alertEngine) notification(rule *AlertRule, a *ActiveAlert, status string, value float64, msg string, now time.Time, repeat bool) pendingNotification {
+	hooks := e.cfg.ruleWebhooks(rule)
+	return pendingNotification{
+		n: AlertNotification{
+			Status: status, Rule: rule.ID, Metric: rule.Metric, Station: a.Station,
+			Value: value, Threshold: rule.Threshold, Message: msg,
+			StartedAt: a.FiredAt, Time: now, Repeat: repeat, Relay: e.hostname,
+		},
+		hooks: hooks,
+	}
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// sampleCRC - Lưu bộ đếm CRC của mỗi worker, bỏ mẫu cũ hơn cửa sổ lớn nhất (gọi khi giữ e.mu)
+func (e *alertEngine) sampleCRC(workers []*Worker, now time.Time) {
+	maxWindow := time.Duration(0)
+	for i := range e.cfg.Rules {
+		if r := &e.cfg.Rules[i]; r.Metric == MetricCRCErrors && !r.Disabled && r.window() > maxWindow {
+			maxWindow = r.window()
+		}
+	}
+	alive := make(map[*Worker]bool, len(workers))
+	for _, worker := range workers {
+		alive[worker] = true
+		if maxWindow == 0 {
+			continue
+		}
+		samples := append(e.crc[worker], counterSample{now, atomic.LoadInt64(&worker.counters.CRCErrors)})
+		cut := 0
+		for cut < len(samples)-1 && now.Sub(samples[cut+1].t) >= maxWindow {
+			cut++
+		}
+		e.crc[worker] = samples[cut:]
+	}
+	for worker := range e.crc {
+		if !alive[worker] || maxWindow == 0 {
+			delete(e.crc, worker)
+		}
+	}
+}
+
+// value - Giá trị metric của rule trên worker và mô tả ngắn cho message (gọi khi giữ e.mu).
+// events: sự kiện của station, đã đọc trước khi khoá (chỉ dùng cho reconnects)
+func (e *alertEngine) value(rule *AlertRule, worker *Worker, events []StationEvent, now time.Time) (float64, string) {
+	switch rule.Metric {
+	case MetricNotRunning:
+		snap := worker.statusSnapshot()
+		if snap.state == StateRunning {
+			return 0, "Running"
+		}
+		d := now.Sub(notRunningSince(snap))
+		return d.Seconds(), fmt.Sprintf("not Running for %s (%s: %s)", formatAlertDuration(d), snap.Status, snap.LastMessage)
+
+	case MetricNoData:
+		// lastFrameTime, không phải lastDataTime: luồng NMEA làm mới lastDataTime sau mỗi GGA
+		last := time.Unix(atomic.LoadInt64(&worker.lastFrameTime), 0)
+		if atomic.LoadInt64(&worker.lastFrameTime) == 0 {
+			last = worker.statusSnapshot().StartTime
+		}
+		d := now.Sub(last)
+		return d.Seconds(), fmt.Sprintf("no RTCM data for %s", formatAlertDuration(d))
+
+	case MetricReconnects:
+		// Đếm từ event log nên vẫn đúng sau khi worker restart (reload config, restart chương trình)
+		from := now.Add(-rule.window())
+		n := 0
+		for _, ev := range events {
+			if causeIndex(ev.Type) >= 0 && ev.Time.After(from) {
+				n++
+			}
+		}
+		return float64(n), fmt.Sprintf("%d reconnects in the last %s", n, formatAlertDuration(rule.window()))
+
+	case MetricCRCErrors:
+		samples := e.crc[worker]
+		if len(samples) == 0 {
+			return 0, "no CRC errors"
+		}
+		base := samples[0]
+		for _, s := range samples {
+			if now.Sub(s.t) < rule.window() {
+				break
+			}
+			base = s
+		}
+		n := samples[len(samples)-1].v - base.v
+		return float64(n), fmt.Sprintf("%d CRC errors in the last %s", n, formatAlertDuration(rule.window()))
+	}
+	return 0, ""
+}
+
+// notRunningSince - Lần cuối station rời Running (chưa Running lần nào thì lúc worker start), để vòng
+// Connecting -> Error -> Waiting khi retry không làm đếm lại từ đầu
+func notRunningSince(snap StationStatus) time.Time {
+	running := StateRunning.String()
+	hist := snap.StateHistory
+	for i := len(hist) - 1; i >= 0; i-- {
+		if hist[i].From == running {
+			return hist[i].Time
+		}
+	}
+	if len(hist) == MaxStateHistory {
+		return hist[0].Time // Lịch sử đã bị cắt: ít nhất từ lần chuyển cũ nhất còn giữ
+	}
+	if snap.StartTime.IsZero() {
+		return snap.StateSince
+	}
+	return snap.StartTime
+}
+
+func formatAlertDuration(d time.Duration) string {
+	return d.Round(time.Second).String()
+}
+
+// deliver - POST notification tới webhook, thử lại WebhookRetries lần (1s, 2s...) khi lỗi mạng hoặc 5xx
+func (e *alertEngine) deliver(hook Webhook, n AlertNotification) WebhookDelivery {
+	d := WebhookDelivery{Time: n.Time, Webhook: hook.Name, Status: n.Status, Rule: n.Rule, Station: n.Station}
+	body, _ := json.Marshal(n)
+	for attempt := 1; attempt <= WebhookRetries; attempt++ {
+		d.Attempts = attempt
+		d.Code, d.Error = 0, ""
+		req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+		if err != nil {
+			d.Error = err.Error()
+			break
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "relayrtcm-alerts")
+		for k, v := range hook.Headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := e.client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			d.Code = resp.StatusCode
+			if resp.StatusCode < 300 {
+				break
+			}
+			d.Error = resp.Status
+			if resp.StatusCode < 500 {
+				break // 4xx: gửi lại cũng vậy
+			}
+		} else {
+			d.Error = err.Error()
+		}
+		if attempt < WebhookRetries {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+	}
+	if d.Error != "" {
+		log.Printf("[Alerts] ❌ Webhook %s failed after %d attempt(s): %s", hook.Name, d.Attempts, d.Error)
+	}
+
+	e.mu.Lock()
+	e.deliveries = append(e.deliveries, d)
+	if len(e.deliveries) > MaxWebhookDeliveries {
+		e.deliveries = e.deliveries[len(e.deliveries)-MaxWebhookDeliveries:]
+	}
+	e.mu.Unlock()
+	return d
+}
+
+// ================= ALERTS API =================
+
+// handleAlerts - GET /api/alerts: alert đang active (firing/chờ for_s) và các lần gửi webhook gần nhất
+func handleAlerts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	alerts.mu.Lock()
+	active := make([]ActiveAlert, 0, len(alerts.active))
+	for _, a := range alerts.active {
+		active = append(active, *a)
+	}
+	deliveries := append([]WebhookDelivery{}, alerts.deliveries...)
+	alerts.mu.Unlock()
+	sort.Slice(active, func(i, j int) bool {
+		if active[i].Rule != active[j].Rule {
+			return active[i].Rule < active[j].Rule
+		}
+		return active[i].Station < active[j].Station
+	})
+	json.NewEncoder(w).Encode(map[string]interface{}{"active": active, "deliveries": deliveries})
+}
+
+// applyAlertConfig - Kiểm tra, lưu alerts.json rồi áp dụng ngay (không cần reload)
+func applyAlertConfig(w http.ResponseWriter, cfg AlertConfig) bool {
+	if err := cfg.validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if err := saveAlertConfig(cfg); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+	alerts.setConfig(cfg)
+	log.Printf("[Alerts] Config updated: %d rule(s), %d webhook(s)", len(cfg.Rules), len(cfg.Webhooks))
+	return true
+}
+
+// handleAlertConfig - GET/PUT /api/alerts/config: toàn bộ alerts.json (interval, webhooks, rules)
+func handleAlertConfig(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case "GET":
+		json.NewEncoder(w).Encode(alerts.config())
+
+	case "PUT":
+		var cfg AlertConfig
+		if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if applyAlertConfig(w, cfg) {
+			json.NewEncoder(w).Encode(cfg)
+		}
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAlertRules - GET/POST /api/alerts/rules
+func handleAlertRules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case "GET":
+		json.NewEncoder(w).Encode(alerts.config().Rules)
+
+	case "POST":
+		// Thêm rule mới
+		var rule AlertRule
+		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		cfg := alerts.config()
+		if cfg.rule(rule.ID) >= 0 {
+			http.Error(w, "ID already exists", http.StatusConflict)
+			return
+		}
+		cfg.Rules = append(append([]AlertRule{}, cfg.Rules...), rule)
+		if applyAlertConfig(w, cfg) {
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(rule)
+		}
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleAlertRuleItem - GET/PUT/DELETE /api/alerts/rules/{id}, POST /api/alerts/rules/{id}/test
+func handleAlertRuleItem(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/alerts/rules/")
+	if strings.HasSuffix(id, "/test") {
+		handleAlertTest(w, r, strings.TrimSuffix(id, "/test"))
+		return
+	}
+	if id == "" {
+		http.Error(w, "ID required", http.StatusBadRequest)
+		return
+	}
+
+	cfg := alerts.config()
+	i := cfg.rule(id)
+	if i < 0 {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	rules := append([]AlertRule{}, cfg.Rules...)
+
+	switch r.Method {
+	case "GET":
+		json.NewEncoder(w).Encode(rules[i])
+
+	case "PUT":
+		var updated AlertRule
+		if err := json.NewDecoder(r.Body).Decode(&updated); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		updated.ID = id // Đảm bảo không đổi ID
+		rules[i] = updated
+		cfg.Rules = rules
+		if applyAlertConfig(w, cfg) {
+			json.NewEncoder(w).Encode(updated)
+		}
+
+	case "DELETE":
+		cfg.Rules = append(rules[:i], rules[i+1:]...)
+		if applyAlertConfig(w, cfg) {
+			w.WriteHeader(http.StatusNoContent)
+		}
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// usesMetric - Có rule đang bật dùng metric này không
+func (c *AlertConfig) usesMetric(metric string) bool {
+	for i := range c.Rules {
+		if c.Rules[i].Metric == metric && !c.Rules[i].Disabled {
+			return true
+		}
+	}
+	return false
+}
+
+// rule - Vị trí rule theo ID, -1 nếu không có
+func (c *AlertConfig) rule(id string) int {
+	for i := range c.Rules {
+		if c.Rules[i].ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// handleAlertTest - POST /api/alerts/rules/{id}/test[?webhook=]: gửi ngay 1 notification status "test"
+// tới các webhook của rule (hoặc chỉ 1 webhook trong số đó) và trả về kết quả gửi.
+// Chỉ gửi tới URL đã cấu hình trong alerts.json, không nhận URL từ request
+func handleAlertTest(w http.ResponseWriter, r *http.Request, id string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	cfg := alerts.config()
+	i := cfg.rule(id)
+	if i < 0 {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	rule := cfg.Rules[i]
+
+	hooks := cfg.ruleWebhooks(&rule)
+	if name := r.URL.Query().Get("webhook"); name != "" {
+		var picked []Webhook
+		for _, h := range hooks {
+			if h.Name == name {
+				picked = append(picked, h)
+			}
+		}
+		if len(picked) == 0 {
+			http.Error(w, fmt.Sprintf("Webhook %q is not configured for this rule", name), http.StatusBadRequest)
+			return
+		}
+		hooks = picked
+	}
+	if len(hooks) == 0 {
+		http.Error(w, "No webhook configured for this rule", http.StatusBadRequest)
+		return
+	}
+
+	station := rule.Station
+	if station == "" {
+		station = "*"
+	}
+	now := time.Now()
+	n := AlertNotification{
+		Status: "test", Rule: rule.ID, Metric: rule.Metric, Station: station,
+		Threshold: rule.Threshold, Message: fmt.Sprintf("Test notification for rule %s", rule.ID),
+		StartedAt: now, Time: now, Relay: alerts.hostname,
+	}
+	results := make([]WebhookDelivery, len(hooks))
+	var wg sync.WaitGroup
+	for j, hook := range hooks {
+		wg.Add(1)
+		go func(j int, hook Webhook) {
+			defer wg.Done()
+			results[j] = alerts.deliver(hook, n)
+		}(j, hook)
+	}
+	wg.Wait()
+	json.NewEncoder(w).Encode(results)
+}
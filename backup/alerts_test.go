@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// webhookReceiver - Webhook giả: ghi lại notification nhận được, status trả về lấy lần lượt từ codes
+// (hết codes thì 200)
+type webhookReceiver struct {
+	srv      *httptest.Server
+	got      chan AlertNotification
+	requests atomic.Int64
+}
+
+func newWebhookReceiver(t testing.TB, codes ...int) *webhookReceiver {
+	r := &webhookReceiver{got: make(chan AlertNotification, 100)}
+	r.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		n := int(r.requests.Add(1))
+		if n <= len(codes) && codes[n-1] != http.StatusOK {
+			w.WriteHeader(codes[n-1])
+			return
+		}
+		var notif AlertNotification
+		json.NewDecoder(req.Body).Decode(&notif)
+		r.got <- notif
+	}))
+	t.Cleanup(r.srv.Close)
+	return r
+}
+
+func (r *webhookReceiver) expect(t testing.TB, status string, repeat bool) AlertNotification {
+	t.Helper()
+	select {
+	case n := <-r.got:
+		if n.Status != status || n.Repeat != repeat {
+			t.Fatalf("got %s (repeat=%v): %s, want %s (repeat=%v)", n.Status, n.Repeat, n.Message, status, repeat)
+		}
+		return n
+	case <-time.After(5 * time.Second):
+		t.Fatalf("no %s notification", status)
+	}
+	return AlertNotification{}
+}
+
+func (r *webhookReceiver) expectNone(t testing.TB) {
+	t.Helper()
+	select {
+	case n := <-r.got:
+		t.Fatalf("unexpected %s notification: %s", n.Status, n.Message)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func testAlertEngine(url string, rules ...AlertRule) *alertEngine {
+	return newAlertEngine(AlertConfig{Webhooks: []Webhook{{Name: "hook", URL: url}}, Rules: rules})
+}
+
+func TestAlertForAndRepeat(t *testing.T) {
+	useTempEventLog(t)
+	recv := newWebhookReceiver(t)
+	e := testAlertEngine(recv.srv.URL, AlertRule{ID: "down", Metric: MetricNotRunning, Threshold: 5, ForS: 30, RepeatS: 60})
+	w := newWorker(testStation("AL1", 1), "", 0) // Chưa Running
+	addTestWorker(t, w)
+
+	t0 := time.Now()
+	at := func(s int) time.Time { return t0.Add(time.Duration(s) * time.Second) }
+
+	e.evaluate(at(10)) // Vượt ngưỡng, chờ đủ for_s
+	e.evaluate(at(30))
+	recv.expectNone(t)
+
+	e.evaluate(at(40)) // Đúng liên tục 30s
+	n := recv.expect(t, "firing", false)
+	if n.Station != "AL1" || n.Rule != "down" {
+		t.Errorf("notification = %+v", n)
+	}
+
+	e.evaluate(at(70)) // Chưa tới repeat_s: không gửi lại
+	e.evaluate(at(90))
+	recv.expectNone(t)
+
+	e.evaluate(at(100))
+	recv.expect(t, "firing", true)
+
+	w.setState(StateRunning, "")
+	e.evaluate(at(110))
+	recv.expect(t, "resolved", false)
+	e.evaluate(at(120))
+	recv.expectNone(t)
+}
+
+func TestAlertResolvedWhenStationRemoved(t *testing.T) {
+	useTempEventLog(t)
+	recv := newWebhookReceiver(t)
+	e := testAlertEngine(recv.srv.URL, AlertRule{ID: "down", Metric: MetricNotRunning, Threshold: 5})
+	w := newWorker(testStation("AL2", 1), "", 0)
+	addTestWorker(t, w)
+
+	now := time.Now()
+	e.evaluate(now.Add(10 * time.Second))
+	recv.expect(t, "firing", false)
+
+	manager.mu.Lock()
+	delete(manager.workers, w.cfg.ID)
+	manager.mu.Unlock()
+	e.evaluate(now.Add(20 * time.Second))
+	if n := recv.expect(t, "resolved", false); !strings.Contains(n.Message, "no longer running") {
+		t.Errorf("message = %q", n.Message)
+	}
+}
+
+// TestAlertNoDataIgnoresNMEA - lastDataTime được luồng NMEA làm mới, no_data phải theo frame RTCM cuối
+func TestAlertNoDataIgnoresNMEA(t *testing.T) {
+	useTempEventLog(t)
+	recv := newWebhookReceiver(t)
+	e := testAlertEngine(recv.srv.URL, AlertRule{ID: "stale", Metric: MetricNoData, Threshold: 60})
+	w := newWorker(testStation("AL3", 1), "", 0)
+	addTestWorker(t, w)
+
+	now := time.Now()
+	atomic.StoreInt64(&w.lastFrameTime, now.Add(-120*time.Second).Unix())
+	atomic.StoreInt64(&w.lastDataTime, now.Unix())
+	e.evaluate(now)
+	recv.expect(t, "firing", false)
+}
+
+func TestAlertReconnectsFromEvents(t *testing.T) {
+	useTempEventLog(t)
+	recv := newWebhookReceiver(t)
+	e := testAlertEngine(recv.srv.URL, AlertRule{ID: "flap", Metric: MetricReconnects, Threshold: 2, WindowS: 600})
+	w := newWorker(testStation("AL4", 1), "", 0)
+	addTestWorker(t, w)
+
+	for i := 0; i < 3; i++ {
+		w.event(StationEvent{Type: EventDestClosed})
+		w.event(StationEvent{Type: EventConnected}) // Không tính
+	}
+	e.evaluate(time.Now())
+	if n := recv.expect(t, "firing", false); n.Value != 3 {
+		t.Errorf("value = %v, want 3", n.Value)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	if testing.Short() {
+		t.Skip("waits for the retry backoff")
+	}
+	e := testAlertEngine("")
+	n := AlertNotification{Status: "firing", Rule: "r", Station: "S", Time: time.Now()}
+
+	recv := newWebhookReceiver(t, http.StatusBadGateway, http.StatusServiceUnavailable)
+	d := e.deliver(Webhook{Name: "hook", URL: recv.srv.URL}, n)
+	if d.Attempts != WebhookRetries || d.Code != http.StatusOK || d.Error != "" || recv.requests.Load() != WebhookRetries {
+		t.Errorf("5xx then 200: delivery = %+v, requests = %d", d, recv.requests.Load())
+	}
+	recv.expect(t, "firing", false)
+
+	recv = newWebhookReceiver(t, 500, 500, 500, 500)
+	d = e.deliver(Webhook{Name: "hook", URL: recv.srv.URL}, n)
+	if d.Attempts != WebhookRetries || d.Code != 500 || d.Error == "" || recv.requests.Load() != WebhookRetries {
+		t.Errorf("always 5xx: delivery = %+v, requests = %d", d, recv.requests.Load())
+	}
+
+	recv = newWebhookReceiver(t, http.StatusUnauthorized)
+	d = e.deliver(Webhook{Name: "hook", URL: recv.srv.URL}, n)
+	if d.Attempts != 1 || d.Code != http.StatusUnauthorized {
+		t.Errorf("4xx must not be retried: delivery = %+v", d)
+	}
+}
+
+func TestAlertTestEndpointOnlyConfiguredWebhooks(t *testing.T) {
+	configured := newWebhookReceiver(t)
+	other := newWebhookReceiver(t)
+	old := alerts
+	alerts = testAlertEngine(configured.srv.URL, AlertRule{ID: "down", Metric: MetricNotRunning, Threshold: 5})
+	t.Cleanup(func() { alerts = old })
+
+	post := func(query string) int {
+		rec := httptest.NewRecorder()
+		handleAlertRuleItem(rec, httptest.NewRequest("POST", "/api/alerts/rules/down/test"+query, nil))
+		return rec.Code
+	}
+
+	// url do người gọi đưa vào bị bỏ qua
+	if code := post("?url=" + other.srv.URL); code != http.StatusOK {
+		t.Fatalf("test endpoint: %d", code)
+	}
+	configured.expect(t, "test", false)
+	other.expectNone(t)
+
+	if code := post("?webhook=hook"); code != http.StatusOK {
+		t.Fatalf("?webhook=hook: %d", code)
+	}
+	configured.expect(t, "test", false)
+
+	if code := post("?webhook=nope"); code != http.StatusBadRequest {
+		t.Errorf("unknown webhook: %d, want 400", code)
+	}
+	configured.expectNone(t)
+}
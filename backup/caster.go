@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ================= NTRIP CASTER NỘI BỘ =================
+// Cho rover trong LAN lấy correction trực tiếp từ relay: mỗi station đang chạy là 1 mountpoint
+// (tên = station ID). Dữ liệu lấy từ hub, không mở thêm kết nối tới source.
+const (
+	CasterWriteTimeout  = 10 * time.Second // Rover không nhận kịp trong 10s -> ngắt
+	CasterHeaderTimeout = 10 * time.Second
+	CasterMountMaxAge   = 60 * time.Second // Station không có data quá 60s -> ẩn khỏi sourcetable
+)
+
+type casterClient struct {
+	id          int64
+	user        string
+	mount       string
+	remoteAddr  string
+	userAgent   string
+	ntripV2     bool
+	connectedAt time.Time
+	bytesSent   int64        // atomic
+	lastGGA     atomic.Value // string
+	sub         *hubSubscriber
+	conn        net.Conn
+}
+
+type CasterClientInfo struct {
+	ID           int64     `json:"id"`
+	User         string    `json:"user"`
+	Mount        string    `json:"mount"`
+	RemoteAddr   string    `json:"remote_addr"`
+	UserAgent    string    `json:"user_agent"`
+	NtripVersion string    `json:"ntrip_version"`
+	ConnectedAt  time.Time `json:"connected_at"`
+	BytesSent    int64     `json:"bytes_sent"`
+	Dropped      int64     `json:"dropped"` // Số lô frame bị bỏ vì rover nhận chậm
+	LastGGA      string    `json:"last_gga,omitempty"`
+}
+
+type ntripCaster struct {
+	settings CasterSettings
+	listener net.Listener
+	mu       sync.Mutex
+	clients  map[*casterClient]struct{}
+	nextID   int64
+}
+
+var caster *ntripCaster // nil nếu caster bị tắt trong settings.json
+
+func startCaster(settings CasterSettings) error {
+	ln, err := net.Listen("tcp", settings.Listen)
+	if err != nil {
+		return fmt.Errorf("caster listen %s: %w", settings.Listen, err)
+	}
+	caster = &ntripCaster{
+		settings: settings,
+		listener: ln,
+		clients:  make(map[*casterClient]struct{}),
+	}
+	log.Printf("NTRIP Caster: ntrip://localhost%s (users: %d)", settings.Listen, len(settings.Users))
+	go caster.serve()
+	return nil
+}
+
+// close - Ngừng nhận rover mới và ngắt các rover đang kết nối (khi tắt chương trình)
+func (c *ntripCaster) close() {
+	c.listener.Close()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for client := range c.clients {
+		client.conn.Close()
+	}
+}
+
+func (c *ntripCaster) serve() {
+	for {
+		conn, err := c.listener.Accept()
+		if err != nil {
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			log.Printf("[Caster] Listener stopped: %v", err)
+			return
+		}
+		go c.handleConn(conn)
+	}
+}
+
+func (c *ntripCaster) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	conn.SetReadDeadline(time.Now().Add(CasterHeaderTimeout))
+	reader := bufio.NewReader(conn)
+	req, err := http.ReadRequest(reader)
+	if err != nil {
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	ntripV2 := strings.HasPrefix(req.Header.Get("Ntrip-Version"), "Ntrip/2")
+	mount := strings.TrimPrefix(req.URL.Path, "/")
+
+	if req.Method != "GET" {
+		writeCasterStatus(conn, ntripV2, "405 Method Not Allowed")
+		return
+	}
+	// GET / -> sourcetable
+	if mount == "" {
+		c.writeSourcetable(conn, ntripV2)
+		return
+	}
+
+	user, ok := c.authorize(req, mount)
+	if !ok {
+		writeCasterStatus(conn, ntripV2, "401 Unauthorized")
+		return
+	}
+	if !casterMountAvailable(mount) {
+		// NTRIP 1.0: mountpoint không tồn tại -> trả sourcetable, NTRIP 2.0 -> 404
+		if ntripV2 {
+			writeCasterStatus(conn, true, "404 Not Found")
+		} else {
+			c.writeSourcetable(conn, false)
+		}
+		return
+	}
+
+	client := &casterClient{
+		id:          atomic.AddInt64(&c.nextID, 1),
+		user:        user,
+		mount:       mount,
+		remoteAddr:  conn.RemoteAddr().String(),
+		userAgent:   req.Header.Get("User-Agent"),
+		ntripV2:     ntripV2,
+		connectedAt: time.Now(),
+		sub:         hub.subscribe(mount, nil),
+		conn:        conn,
+	}
+	defer client.sub.close()
+
+	if ntripV2 {
+		conn.Write([]byte("HTTP/1.1 200 OK\r\nNtrip-Version: Ntrip/2.0\r\nServer: NTRIP relayrtcm\r\nContent-Type: gnss/data\r\nCache-Control: no-store\r\nConnection: close\r\n\r\n"))
+	} else {
+		conn.Write([]byte("ICY 200 OK\r\n"))
+	}
+
+	c.mu.Lock()
+	c.clients[client] = struct{}{}
+	c.mu.Unlock()
+	log.Printf("[Caster] Client #%d connected: %s@%s -> %s (%s)", client.id, user, client.remoteAddr, mount, client.userAgent)
+
+	defer func() {
+		c.mu.Lock()
+		delete(c.clients, client)
+		c.mu.Unlock()
+		log.Printf("[Caster] Client #%d disconnected: %s (sent %d bytes, %s)",
+			client.id, mount, atomic.LoadInt64(&client.bytesSent), time.Since(client.connectedAt).Round(time.Second))
+	}()
+
+	// Luồng phụ: đọc GGA từ rover (chỉ lưu lại để hiển thị), rover ngắt -> kết thúc
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			if strings.Contains(line, "GGA") {
+				client.lastGGA.Store(strings.TrimSpace(line))
+			}
+		}
+	}()
+
+	for {
+		select {
+		case data := <-client.sub.C:
+			conn.SetWriteDeadline(time.Now().Add(CasterWriteTimeout))
+			n, err := conn.Write(data)
+			atomic.AddInt64(&client.bytesSent, int64(n))
+			if err != nil {
+				return
+			}
+		case <-done:
+			return
+		}
+	}
+}
+
+// authorize - Basic auth theo danh sách user trong settings (không có user = truy cập tự do)
+func (c *ntripCaster) authorize(req *http.Request, mount string) (string, bool) {
+	if len(c.settings.Users) == 0 {
+		return "anonymous", true
+	}
+	user, pass, ok := req.BasicAuth()
+	if !ok {
+		return "", false
+	}
+	for _, u := range c.settings.Users {
+		if u.User != user || u.Pass != pass {
+			continue
+		}
+		if len(u.Mounts) == 0 {
+			return user, true
+		}
+		for _, m := range u.Mounts {
+			if m == mount {
+				return user, true
+			}
+		}
+		return user, false
+	}
+	return "", false
+}
+
+func writeCasterStatus(conn net.Conn, ntripV2 bool, status string) {
+	if ntripV2 {
+		extra := ""
+		if strings.HasPrefix(status, "401") {
+			extra = "WWW-Authenticate: Basic realm=\"NTRIP Relay\"\r\n"
+		}
+		fmt.Fprintf(conn, "HTTP/1.1 %s\r\nNtrip-Version: Ntrip/2.0\r\nServer: NTRIP relayrtcm\r\n%sConnection: close\r\n\r\n", status, extra)
+		return
+	}
+	fmt.Fprintf(conn, "HTTP/1.0 %s\r\n\r\n", status)
+}
+
+// writeSourcetable - Sourcetable sinh từ các station đang có data
+func (c *ntripCaster) writeSourcetable(conn net.Conn, ntripV2 bool) {
+	var body strings.Builder
+	host, port := "localhost", 2101
+	if addr, ok := c.listener.Addr().(*net.TCPAddr); ok {
+		port = addr.Port
+	}
+	fmt.Fprintf(&body, "CAS;%s;%d;%s;%s;0;%s;0.00;0.00;0.0.0.0;0;\r\n",
+		host, port, c.settings.Identifier, c.settings.Operator, c.settings.Country)
+
+	auth := "N"
+	if len(c.settings.Users) > 0 {
+		auth = "B"
+	}
+	for _, m := range casterMountList() {
+		fmt.Fprintf(&body, "STR;%s;%s;RTCM 3;%s;2;%s;%s;%s;%.2f;%.2f;0;0;relayrtcm;none;%s;N;0;\r\n",
+			m.mount, m.mount, m.formatDetails, m.navSystem, c.settings.Identifier, c.settings.Country, m.lat, m.lon, auth)
+	}
+	body.WriteString("ENDSOURCETABLE\r\n")
+
+	if ntripV2 {
+		fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nNtrip-Version: Ntrip/2.0\r\nServer: NTRIP relayrtcm\r\nContent-Type: gnss/sourcetable\r\nContent-Length: %d\r\nConnection: close\r\n\r\n", body.Len())
+	} else {
+		fmt.Fprintf(conn, "SOURCETABLE 200 OK\r\nServer: NTRIP relayrtcm\r\nContent-Type: text/plain\r\nContent-Length: %d\r\n\r\n", body.Len())
+	}
+	conn.Write([]byte(body.String()))
+}
+
+// clientList - Danh sách rover đang kết nối
+func (c *ntripCaster) clientList() []CasterClientInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := make([]CasterClientInfo, 0, len(c.clients))
+	for client := range c.clients {
+		info := CasterClientInfo{
+			ID:           client.id,
+			User:         client.user,
+			Mount:        client.mount,
+			RemoteAddr:   client.remoteAddr,
+			UserAgent:    client.userAgent,
+			NtripVersion: "Ntrip/1.0",
+			ConnectedAt:  client.connectedAt,
+			BytesSent:    atomic.LoadInt64(&client.bytesSent),
+			Dropped:      atomic.LoadInt64(&client.sub.dropped),
+		}
+		if client.ntripV2 {
+			info.NtripVersion = "Ntrip/2.0"
+		}
+		if gga, ok := client.lastGGA.Load().(string); ok {
+			info.LastGGA = gga
+		}
+		result = append(result, info)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+// clientCount - Số rover đang nhận 1 mountpoint
+func (c *ntripCaster) clientCount(mount string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for client := range c.clients {
+		if client.mount == mount {
+			n++
+		}
+	}
+	return n
+}
+
+// ================= MOUNTPOINT INFO (từ các worker đang chạy) =================
+type casterMount struct {
+	mount         string
+	lat, lon      float64
+	formatDetails string
+	navSystem     string
+}
+
+func casterMountAvailable(mount string) bool {
+	for _, m := range casterMountList() {
+		if m.mount == mount {
+			return true
+		}
+	}
+	return false
+}
+
+func casterMountList() []casterMount {
+	manager.mu.RLock()
+	defer manager.mu.RUnlock()
+
+	now := time.Now()
+	mounts := make([]casterMount, 0, len(manager.workers))
+	for id, worker := range manager.workers {
+		// Station src_ref không publish lên hub (data đã có ở mountpoint của station nguồn)
+		if worker.cfg.SrcRef != "" {
+			continue
+		}
+		// Theo frame RTCM cuối: luồng NMEA làm mới lastDataTime kể cả khi nguồn đã ngừng
+		lastFrame := time.Unix(atomic.LoadInt64(&worker.lastFrameTime), 0)
+		if now.Sub(lastFrame) > CasterMountMaxAge {
+			continue
+		}
+		live := worker.settings()
+		m := casterMount{mount: id, lat: live.Lat, lon: live.Lon}
+		if pos := worker.rtcmPos.Load(); pos != nil {
+			m.lat, m.lon = pos.Lat, pos.Lon
+		}
+
+		// Format details: "1005(10),1077(1)" - số trong ngoặc là chu kỳ (giây)
+		var details, systems []string
+		seen := make(map[string]bool)
+		for _, t := range worker.msgStats.snapshot(now) {
+			period := 0
+			if t.RateHz > 0 {
+				period = int(1/t.RateHz + 0.5)
+			}
+			details = append(details, fmt.Sprintf("%d(%d)", t.Type, period))
+			if sys := rtcmNavSystem(t.Type); sys != "" && !seen[sys] {
+				seen[sys] = true
+				systems = append(systems, sys)
+			}
+		}
+		m.formatDetails = strings.Join(details, ",")
+		m.navSystem = strings.Join(systems, "+")
+		mounts = append(mounts, m)
+	}
+	sort.Slice(mounts, func(i, j int) bool { return mounts[i].mount < mounts[j].mount })
+	return mounts
+}
+
+// rtcmNavSystem - Hệ vệ tinh của message MSM (1071-1137)
+func rtcmNavSystem(msgType int) string {
+	switch {
+	case msgType >= 1071 && msgType <= 1077:
+		return "GPS"
+	case msgType >= 1081 && msgType <= 1087:
+		return "GLO"
+	case msgType >= 1091 && msgType <= 1097:
+		return "GAL"
+	case msgType >= 1111 && msgType <= 1117:
+		return "QZS"
+	case msgType >= 1121 && msgType <= 1127:
+		return "BDS"
+	}
+	return ""
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestCasterMountListIgnoresNMEA - Nguồn đã ngừng nhưng GGA vẫn làm mới lastDataTime:
+// mountpoint phải biến khỏi sourcetable theo frame RTCM cuối
+func TestCasterMountListIgnoresNMEA(t *testing.T) {
+	w := newWorker(testStation("CM1", 1), "", 0)
+	addTestWorker(t, w)
+
+	listed := func() bool {
+		for _, m := range casterMountList() {
+			if m.mount == "CM1" {
+				return true
+			}
+		}
+		return false
+	}
+
+	now := time.Now().Unix()
+	atomic.StoreInt64(&w.lastFrameTime, now)
+	if !listed() {
+		t.Fatal("mount with a fresh RTCM frame not listed")
+	}
+
+	atomic.StoreInt64(&w.lastFrameTime, now-int64(CasterMountMaxAge/time.Second)-10)
+	atomic.StoreInt64(&w.lastDataTime, now) // GGA vừa gửi
+	if listed() {
+		t.Error("stale mount listed because of the NMEA refresh")
+	}
+}
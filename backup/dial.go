@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ================= DIAL LIMITER =================
+// Sau khi khởi động lại hoặc reload config, mọi worker cùng dial 1 lúc có thể làm nghẽn
+// proxy/caster. Mọi lần dial (Source lẫn Dest) đi qua 1 limiter chung của StationManager:
+// tối đa max_concurrent kết nối đang dial, và không quá per_second lần dial mỗi giây.
+const (
+	DefaultMaxConcurrentDials = 20
+	DefaultDialsPerSecond     = 10
+)
+
+type DialSettings struct {
+	MaxConcurrent int     `json:"max_concurrent"` // Số kết nối dial đồng thời tối đa (0 = không giới hạn)
+	PerSecond     float64 `json:"per_second"`     // Số lần dial mỗi giây (0 = không giới hạn)
+	Burst         int     `json:"burst"`          // Số lần dial liên tiếp được phép khi bucket đầy (mặc định = per_second)
+}
+
+type dialLimiter struct {
+	sem chan struct{} // nil = không giới hạn số dial đồng thời
+
+	mu     sync.Mutex
+	rate   float64 // token/giây, 0 = không giới hạn
+	burst  float64
+	tokens float64
+	last   time.Time
+
+	queued int64 // Số worker đang chờ dial (atomic)
+}
+
+func newDialLimiter(s DialSettings) *dialLimiter {
+	l := &dialLimiter{rate: s.PerSecond, last: time.Now()}
+	if s.MaxConcurrent > 0 {
+		l.sem = make(chan struct{}, s.MaxConcurrent)
+	}
+	if l.rate > 0 {
+		l.burst = float64(s.Burst)
+		if l.burst < 1 {
+			l.burst = max(l.rate, 1)
+		}
+		l.tokens = l.burst
+	}
+	return l
+}
+
+// reserve - Lấy 1 token, trả về thời gian phải chờ trước khi được dial
+func (l *dialLimiter) reserve() time.Duration {
+	if l.rate <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(time.Second))
+}
+
+// acquire - Chờ tới lượt dial. queued được gọi 1 lần nếu phải xếp hàng.
+// Gọi release() khi dial xong (thành công hay lỗi).
+func (l *dialLimiter) acquire(ctx context.Context, queued func()) (release func(), err error) {
+	if l == nil {
+		return func() {}, nil
+	}
+	waiting := false
+	wait := func() {
+		if !waiting {
+			waiting = true
+			atomic.AddInt64(&l.queued, 1)
+			if queued != nil {
+				queued()
+			}
+		}
+	}
+	defer func() {
+		if waiting {
+			atomic.AddInt64(&l.queued, -1)
+		}
+	}()
+
+	if d := l.reserve(); d > 0 {
+		wait()
+		timer := time.NewTimer(d)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+
+	if l.sem == nil {
+		return func() {}, nil
+	}
+	select {
+	case l.sem <- struct{}{}:
+	default:
+		wait()
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case l.sem <- struct{}{}:
+		}
+	}
+	var once sync.Once
+	return func() { once.Do(func() { <-l.sem }) }, nil
+}
+
+// dial - connectToHost qua limiter chung, hiển thị "Queued for dial" khi phải chờ.
+// probe = kết nối thử của failback (chạy song song session đang Running): vẫn xếp hàng
+// nhưng không được đụng tới trạng thái station.
+// Thời gian dial (không tính lúc xếp hàng) ghi vào latency khi thành công.
+func (w *Worker) dial(ctx context.Context, latency *latencyHistogram, host string, port int, proxyURL string, useSSL bool, tlsOpts *TLSSettings, probe bool) (net.Conn, error) {
+	prev := w.state()
+	queued := false
+	release, err := manager.dials.acquire(ctx, func() {
+		log.Printf("[%s] ⏳ Queued for dial %s:%d (%d waiting)", w.cfg.ID, host, port, atomic.LoadInt64(&manager.dials.queued))
+		if !probe {
+			queued = true
+			w.setState(StateQueued, "")
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("wait for dial slot: %w", err)
+	}
+	defer release()
+	if queued {
+		// Chỉ trả lại trạng thái cũ nếu trong lúc chờ không có ai chuyển trạng thái khác
+		w.updateStatus(func(s *StationStatus) {
+			if s.state == StateQueued {
+				s.transition(prev, "")
+			}
+		})
+	}
+	start := time.Now()
+	conn, err := connectToHost(ctx, host, port, proxyURL, useSSL, tlsOpts)
+	if err == nil {
+		latency.observe(time.Since(start))
+	}
+	return conn, err
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDialLimiterConcurrency(t *testing.T) {
+	l := newDialLimiter(DialSettings{MaxConcurrent: 3})
+	var active, peak, queued atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 12; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := l.acquire(context.Background(), func() { queued.Add(1) })
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			n := active.Add(1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			time.Sleep(20 * time.Millisecond)
+			active.Add(-1)
+			release()
+			release() // Gọi 2 lần không được trả slot 2 lần
+		}()
+	}
+	wg.Wait()
+	if p := peak.Load(); p != 3 {
+		t.Errorf("peak concurrent dials = %d, want 3", p)
+	}
+	if queued.Load() == 0 {
+		t.Error("queued callback never called")
+	}
+	if q := atomic.LoadInt64(&l.queued); q != 0 || len(l.sem) != 0 {
+		t.Errorf("after all releases: queued = %d, slots in use = %d", q, len(l.sem))
+	}
+}
+
+func TestDialLimiterCancelWhileQueued(t *testing.T) {
+	l := newDialLimiter(DialSettings{MaxConcurrent: 1})
+	release, _ := l.acquire(context.Background(), nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := l.acquire(ctx, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("acquire on a full limiter = %v, want deadline exceeded", err)
+	}
+	if q := atomic.LoadInt64(&l.queued); q != 0 {
+		t.Errorf("queued = %d after the waiter gave up", q)
+	}
+
+	release()
+	if r, err := l.acquire(context.Background(), nil); err != nil {
+		t.Errorf("slot not returned after release: %v", err)
+	} else {
+		r()
+	}
+}
+
+func TestDialLimiterTokenBucket(t *testing.T) {
+	l := newDialLimiter(DialSettings{PerSecond: 10, Burst: 3})
+	for i := 0; i < 3; i++ {
+		if d := l.reserve(); d != 0 {
+			t.Fatalf("burst dial %d waits %v", i, d)
+		}
+	}
+	// Bucket rỗng: lần 4 chờ ~1/rate, lần 5 chờ ~2/rate
+	if d := l.reserve(); d < 80*time.Millisecond || d > 100*time.Millisecond {
+		t.Errorf("4th dial waits %v, want ~100ms", d)
+	}
+	if d := l.reserve(); d < 180*time.Millisecond || d > 200*time.Millisecond {
+		t.Errorf("5th dial waits %v, want ~200ms", d)
+	}
+
+	// Nạp lại theo thời gian nhưng không vượt burst: lùi mốc 10s thay vì ngủ
+	l.mu.Lock()
+	l.last = l.last.Add(-10 * time.Second)
+	l.mu.Unlock()
+	for i := 0; i < 3; i++ {
+		if d := l.reserve(); d != 0 {
+			t.Fatalf("after refill, dial %d waits %v", i, d)
+		}
+	}
+	if d := l.reserve(); d == 0 {
+		t.Error("refill exceeded burst")
+	}
+
+	// Mặc định burst = per_second; per_second = 0 là không giới hạn
+	if l := newDialLimiter(DialSettings{PerSecond: 5}); l.burst != 5 {
+		t.Errorf("default burst = %v, want 5", l.burst)
+	}
+	unlimited := newDialLimiter(DialSettings{})
+	for i := 0; i < 100; i++ {
+		if d := unlimited.reserve(); d != 0 {
+			t.Fatalf("unlimited limiter waits %v", d)
+		}
+	}
+}
+
+// TestDialLimiterAcquireWaitsForToken - acquire phải thực sự chờ token và báo queued
+func TestDialLimiterAcquireWaitsForToken(t *testing.T) {
+	l := newDialLimiter(DialSettings{PerSecond: 20, Burst: 1})
+	release, _ := l.acquire(context.Background(), nil)
+	release()
+
+	start := time.Now()
+	queued := false
+	release, err := l.acquire(context.Background(), func() { queued = true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if waited := time.Since(start); waited < 40*time.Millisecond || !queued {
+		t.Errorf("second dial waited %v (queued=%v), want ~50ms and queued", waited, queued)
+	}
+
+	// Limiter nil (chưa khởi tạo manager): không giới hạn
+	if r, err := (*dialLimiter)(nil).acquire(context.Background(), nil); err != nil {
+		t.Errorf("nil limiter: %v", err)
+	} else {
+		r()
+	}
+}
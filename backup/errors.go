@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"syscall"
+)
+
+// ================= ERROR MODEL =================
+// Mỗi lỗi của session mang 1 loại (sentinel) để chọn chiến lược retry bằng errors.Is,
+// thay vì dò chuỗi trong err.Error(). Message gốc giữ nguyên để hiển thị.
+var (
+	// Caster từ chối: thử lại ngay vô ích -> chờ lâu (BlockRetryDelay)
+	ErrAuth                = errors.New("authentication failed")          // 401/403
+	ErrMountNotFound       = errors.New("mountpoint not found")           // 404
+	ErrSourcetableReturned = errors.New("caster returned sourcetable")    // NTRIP 1.0: mount không tồn tại
+	ErrRejected            = errors.New("request rejected")               // Mã trạng thái khác không xử lý được
+	ErrClosedOnConnect     = errors.New("server closed during handshake") // Caster đóng ngay khi nhận request
+	ErrTLS                 = errors.New("tls handshake failed")
+	ErrProxyAuth           = errors.New("proxy authentication failed") // Proxy trả 407
+
+	// Lỗi mạng tạm thời -> retry với backoff
+	ErrUnavailable = errors.New("caster unavailable") // 5xx
+	ErrDNS         = errors.New("dns lookup failed")
+	ErrProxy       = errors.New("proxy error")
+	ErrRefused     = errors.New("connection refused")
+	ErrTimeout     = errors.New("timeout")
+	ErrClosed      = errors.New("connection closed")
+	ErrStale       = errors.New("no data (stale)") // Kết nối còn sống nhưng không có data
+	ErrNetwork     = errors.New("network error")
+)
+
+// relayError - Gắn loại lỗi vào lỗi gốc: errors.Is khớp cả Kind lẫn chuỗi lỗi gốc
+type relayError struct {
+	Kind error
+	Err  error
+}
+
+func (e *relayError) Error() string   { return e.Err.Error() }
+func (e *relayError) Unwrap() []error { return []error{e.Kind, e.Err} }
+
+func wrapError(kind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &relayError{Kind: kind, Err: err}
+}
+
+// classifyNetError - Phân loại lỗi từ net/io (dial, read, write)
+func classifyNetError(err error) error {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return err
+	}
+	var relayErr *relayError
+	if errors.As(err, &relayErr) {
+		return err // Đã phân loại
+	}
+
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &dnsErr):
+		return wrapError(ErrDNS, err)
+	case errors.As(err, &opErr) && opErr.Op == "remote error":
+		// Alert TLS từ server (TLS 1.3 báo thiếu/sai client cert ở lần đọc đầu tiên)
+		return wrapError(ErrTLS, err)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return wrapError(ErrRefused, err)
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, net.ErrClosed),
+		errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE):
+		return wrapError(ErrClosed, err)
+	case errors.Is(err, os.ErrDeadlineExceeded), errors.Is(err, context.DeadlineExceeded),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return wrapError(ErrTimeout, err)
+	}
+	return wrapError(ErrNetwork, err)
+}
+
+var (
+	blockingKinds = []error{ErrAuth, ErrMountNotFound, ErrSourcetableReturned, ErrRejected, ErrClosedOnConnect, ErrTLS, ErrProxyAuth}
+	networkKinds  = []error{ErrUnavailable, ErrDNS, ErrProxy, ErrRefused, ErrTimeout, ErrClosed, ErrStale, ErrNetwork}
+)
+
+// isBlockingError - Caster từ chối (sai tài khoản, mount không tồn tại...): chờ lâu trước khi thử lại
+func isBlockingError(err error) bool {
+	for _, kind := range blockingKinds {
+		if errors.Is(err, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNetworkError - Lỗi mạng tạm thời: retry nhanh với exponential backoff
+func isNetworkError(err error) bool {
+	for _, kind := range networkKinds {
+		if errors.Is(err, kind) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorKind - Tên loại lỗi để hiển thị trong status ("" nếu chưa phân loại)
+func errorKind(err error) string {
+	for _, kinds := range [][]error{blockingKinds, networkKinds} {
+		for _, kind := range kinds {
+			if errors.Is(err, kind) {
+				return kind.Error()
+			}
+		}
+	}
+	return ""
+}
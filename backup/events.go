@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ================= STATION EVENTS =================
+// LastMessage chỉ giữ lỗi gần nhất. Mỗi station có thêm 1 vòng MaxStationEvents sự kiện có loại
+// (connecting, connected, auth_failed, stale, dest_closed, failover...) kèm thời điểm và độ dài.
+// Sự kiện ghi nối tiếp vào events/<station>.jsonl nên còn sau khi restart chương trình;
+// file dài quá 2 lần vòng thì được ghi lại chỉ với các sự kiện còn trong vòng.
+const (
+	EventDir         = "events"
+	MaxStationEvents = 200
+)
+
+type EventType string
+
+const (
+	EventStarted       EventType = "started"        // Worker khởi động (chạy chương trình, thêm/bật station, restart do đổi config)
+	EventConnecting    EventType = "connecting"     // Bắt đầu session
+	EventConnected     EventType = "connected"      // Đang chuyển data (duration: thời gian kết nối)
+	EventAuthFailed    EventType = "auth_failed"    // Caster/proxy từ chối tài khoản
+	EventStale         EventType = "stale"          // Kết nối còn nhưng không có data
+	EventSourceClosed  EventType = "source_closed"  // Source đóng kết nối
+	EventDestClosed    EventType = "dest_closed"    // Dest đóng kết nối
+	EventError         EventType = "error"          // Lỗi khác (duration: độ dài session)
+	EventFailover      EventType = "failover"       // Chuyển sang source dự phòng
+	EventFailback      EventType = "failback"       // Quay về source chính
+	EventConfigChanged EventType = "config_changed" // Reload: đổi config / áp dụng trường live / xoá station
+	EventStopped       EventType = "stopped"        // Worker dừng (reload, tắt chương trình)
+)
+
+type StationEvent struct {
+	Time      time.Time `json:"time"`
+	Type      EventType `json:"type"`
+	Message   string    `json:"message,omitempty"`
+	ErrorKind string    `json:"error_kind,omitempty"`
+	Duration  float64   `json:"duration_s,omitempty"` // connected: thời gian kết nối, lỗi/stopped: độ dài session
+}
+
+type eventRing struct {
+	events []StationEvent
+	lines  int // Số dòng trong file (gồm cả sự kiện đã rơi khỏi vòng)
+}
+
+type eventLog struct {
+	dir string
+
+	mu    sync.Mutex
+	rings map[string]*eventRing // Nạp từ file lần đầu dùng tới
+}
+
+var stationEvents = &eventLog{dir: EventDir, rings: make(map[string]*eventRing)}
+
+func (l *eventLog) path(id string) string {
+	return filepath.Join(l.dir, safeFileName(id)+".jsonl")
+}
+
+// ring - Vòng sự kiện của station (gọi khi giữ l.mu)
+func (l *eventLog) ring(id string) *eventRing {
+	r, ok := l.rings[id]
+	if !ok {
+		r = l.load(id)
+		l.rings[id] = r
+	}
+	return r
+}
+
+// load - Đọc file sự kiện của station (chưa có file = vòng rỗng)
+func (l *eventLog) load(id string) *eventRing {
+	r := &eventRing{}
+	if f, err := os.Open(l.path(id)); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var ev StationEvent
+			if json.Unmarshal(scanner.Bytes(), &ev) == nil {
+				r.events = append(r.events, ev)
+			}
+			r.lines++
+		}
+		f.Close()
+		if len(r.events) > MaxStationEvents {
+			r.events = r.events[len(r.events)-MaxStationEvents:]
+		}
+	}
+	return r
+}
+
+// record - Thêm sự kiện vào vòng và ghi nối tiếp vào file
+func (l *eventLog) record(id string, ev StationEvent) {
+	if ev.Time.IsZero() {
+		ev.Time = time.Now()
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r := l.ring(id)
+	r.events = append(r.events, ev)
+	if len(r.events) > MaxStationEvents {
+		r.events = r.events[len(r.events)-MaxStationEvents:]
+	}
+
+	var err error
+	if r.lines >= 2*MaxStationEvents {
+		err = l.rewrite(id, r)
+	} else {
+		err = l.append(id, ev)
+		r.lines++
+	}
+	if err != nil {
+		log.Printf("[%s] ❌ Save event failed: %v", id, err)
+	}
+}
+
+func (l *eventLog) append(id string, ev StationEvent) error {
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return err
+	}
+	line, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path(id), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// rewrite - Ghi lại file chỉ với các sự kiện còn trong vòng (file tạm rồi rename)
+func (l *eventLog) rewrite(id string, r *eventRing) error {
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return err
+	}
+	var buf strings.Builder
+	for _, ev := range r.events {
+		line, _ := json.Marshal(ev)
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	tmp := l.path(id) + ".tmp"
+	if err := os.WriteFile(tmp, []byte(buf.String()), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, l.path(id)); err != nil {
+		return err
+	}
+	r.lines = len(r.events)
+	return nil
+}
+
+// list - Bản sao các sự kiện của station (cũ -> mới). Station chưa ghi sự kiện nào trong lần chạy này
+// thì đọc thẳng từ file, không giữ lại (API có thể hỏi ID bất kỳ)
+func (l *eventLog) list(id string) []StationEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	r, ok := l.rings[id]
+	if !ok {
+		r = l.load(id)
+	}
+	return append([]StationEvent{}, r.events...)
+}
+
+func (w *Worker) event(ev StationEvent) {
+	if i := causeIndex(ev.Type); i >= 0 {
+		atomic.AddInt64(&w.counters.Causes[i], 1)
+	}
+	stationEvents.record(w.cfg.ID, ev)
+}
+
+// sessionEventType - Loại sự kiện khi session kết thúc với lỗi err
+func sessionEventType(err error) EventType {
+	var srcErr *sourceError
+	switch {
+	case errors.Is(err, ErrAuth), errors.Is(err, ErrProxyAuth):
+		return EventAuthFailed
+	case errors.Is(err, ErrStale):
+		return EventStale
+	case errors.Is(err, ErrClosed) && errors.As(err, &srcErr):
+		return EventSourceClosed
+	case errors.Is(err, ErrClosed):
+		return EventDestClosed
+	}
+	return EventError
+}
+
+// handleStationEvents - GET /api/stations/{id}/events?limit=&type=
+// limit: chỉ lấy N sự kiện mới nhất, type: lọc theo loại (phân cách bằng dấu phẩy)
+func handleStationEvents(w http.ResponseWriter, r *http.Request, id string) {
+	list := stationEvents.list(id)
+
+	q := r.URL.Query()
+	if v := q.Get("type"); v != "" {
+		types := strings.Split(v, ",")
+		filtered := list[:0]
+		for _, ev := range list {
+			for _, t := range types {
+				if string(ev.Type) == strings.TrimSpace(t) {
+					filtered = append(filtered, ev)
+					break
+				}
+			}
+		}
+		list = filtered
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if len(list) > limit {
+			list = list[len(list)-limit:]
+		}
+	}
+	json.NewEncoder(w).Encode(list)
+}
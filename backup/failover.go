@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// ================= SOURCE FAILOVER =================
+// Source chính là các trường src_* của station, src_backups là danh sách source dự phòng theo thứ tự ưu tiên.
+// Source hiện tại lỗi liên tiếp failover_after session (hoặc không có data failover_no_data_s giây)
+// -> chuyển sang source kế tiếp. Khi đang chạy source dự phòng, định kỳ kết nối thử source chính,
+// nhận được frame RTCM hợp lệ thì quay về.
+const (
+	DefaultFailoverAfter    = 3                 // Số session lỗi liên tiếp trước khi chuyển source
+	DefaultFailbackInterval = 120 * time.Second // Chu kỳ kiểm tra source chính khi đang chạy dự phòng
+	FailbackProbeTimeout    = 20 * time.Second  // Thời gian tối đa để source chính trả về 1 frame
+	MaxSourceSwitches       = 20                // Số lần chuyển source giữ lại trong status
+)
+
+type ConfigSource struct {
+	Host   string `json:"host"`
+	Port   int    `json:"port"`
+	Mount  string `json:"mount"`
+	User   string `json:"user"`
+	Pass   string `json:"pass"`
+	Proxy  string `json:"proxy,omitempty"`
+	UseSSL bool   `json:"use_ssl,omitempty"`
+
+	TLS *TLSSettings `json:"tls,omitempty"`
+}
+
+func (s ConfigSource) label() string {
+	return fmt.Sprintf("%s:%d/%s", s.Host, s.Port, s.Mount)
+}
+
+type SourceSwitch struct {
+	Time   time.Time `json:"time"`
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Reason string    `json:"reason"`
+}
+
+var errSourceFailback = errors.New("primary source healthy again")
+
+// sourceError - Lỗi phía Source (dial, auth, đọc...). Lỗi phía Dest không tính vào failover.
+type sourceError struct {
+	err error
+}
+
+func (e *sourceError) Error() string { return e.err.Error() }
+func (e *sourceError) Unwrap() error { return e.err }
+
+// sources - Danh sách source theo thứ tự ưu tiên (phần tử 0 là source chính)
+func (w *Worker) sources() []ConfigSource {
+	primary := ConfigSource{
+		Host:   w.cfg.SrcHost,
+		Port:   w.cfg.SrcPort,
+		Mount:  w.cfg.SrcMount,
+		User:   w.cfg.SrcUser,
+		Pass:   w.cfg.SrcPass,
+		Proxy:  w.cfg.SrcProxy,
+		UseSSL: w.cfg.SrcUseSSL,
+		TLS:    w.cfg.SrcTLS,
+	}
+	return append([]ConfigSource{primary}, w.cfg.SrcBackups...)
+}
+
+func (w *Worker) activeSource() ConfigSource {
+	sources := w.sources()
+	if w.srcIndex >= len(sources) {
+		w.srcIndex = 0
+	}
+	return sources[w.srcIndex]
+}
+
+// sourceReadTimeout - Có source dự phòng và khai báo failover_no_data_s thì phát hiện mất data sớm hơn ReadTimeout
+func (w *Worker) sourceReadTimeout() time.Duration {
+	if noData := w.settings().FailoverNoData; len(w.cfg.SrcBackups) > 0 && noData > 0 {
+		if d := time.Duration(noData) * time.Second; d < ReadTimeout {
+			return d
+		}
+	}
+	return ReadTimeout
+}
+
+func (w *Worker) failoverAfter() int {
+	if n := w.settings().FailoverAfter; n > 0 {
+		return n
+	}
+	return DefaultFailoverAfter
+}
+
+func (w *Worker) failbackInterval() time.Duration {
+	if s := w.settings().FailbackInterval; s > 0 {
+		return time.Duration(s) * time.Second
+	}
+	return DefaultFailbackInterval
+}
+
+// failoverAfterSession - Cập nhật bộ đếm lỗi của source hiện tại sau 1 session lỗi.
+// Trả về true nếu vừa chuyển source (thử source mới ngay, không tính backoff của source cũ).
+func (w *Worker) failoverAfterSession(err error, runDuration time.Duration) bool {
+	sources := w.sources()
+	if len(sources) < 2 {
+		return false
+	}
+	if errors.Is(err, errSourceFailback) {
+		w.switchSource(0, "primary source healthy again")
+		return true
+	}
+
+	var srcErr *sourceError
+	if !errors.As(err, &srcErr) {
+		return false // Lỗi phía Dest: source vẫn tốt
+	}
+	noData := errors.Is(err, ErrStale)
+	if runDuration >= MinStableSessionTime && !noData {
+		// Session đã chạy ổn định rồi mới lỗi: thử lại cùng source
+		w.srcFailures = 0
+		return false
+	}
+
+	w.srcFailures++
+	next := (w.srcIndex + 1) % len(sources)
+	if secs := w.settings().FailoverNoData; noData && secs > 0 {
+		w.switchSource(next, fmt.Sprintf("no data for %ds", secs))
+		return true
+	}
+	if w.srcFailures >= w.failoverAfter() {
+		w.switchSource(next, fmt.Sprintf("%d failed sessions, last: %v", w.srcFailures, err))
+		return true
+	}
+	return false
+}
+
+func (w *Worker) switchSource(idx int, reason string) {
+	sources := w.sources()
+	from := w.activeSource().label()
+	w.srcIndex = idx
+	w.srcFailures = 0
+	to := sources[idx].label()
+
+	sw := SourceSwitch{Time: time.Now(), From: from, To: to, Reason: reason}
+	w.updateStatus(func(s *StationStatus) {
+		switches := append(s.SourceSwitches, sw)
+		if len(switches) > MaxSourceSwitches {
+			switches = switches[len(switches)-MaxSourceSwitches:]
+		}
+		s.SourceSwitches = switches
+		s.ActiveSource = to
+		s.SourceIndex = idx
+	})
+
+	typ := EventFailover
+	if idx == 0 {
+		typ = EventFailback
+	}
+	w.event(StationEvent{Time: sw.Time, Type: typ, Message: fmt.Sprintf("%s -> %s (%s)", from, to, reason)})
+
+	if idx == 0 {
+		log.Printf("[%s] 🔁 Failback %s -> %s (%s)", w.cfg.ID, from, to, reason)
+	} else {
+		log.Printf("[%s] 🔁 Failover %s -> %s (backup #%d, %s)", w.cfg.ID, from, to, idx, reason)
+	}
+}
+
+// watchPrimary - Chạy trong session dùng source dự phòng: kiểm tra source chính định kỳ,
+// source chính trả data lại thì kết thúc session (trả errSourceFailback) để quay về
+func (w *Worker) watchPrimary(ctx context.Context) error {
+	ticker := time.NewTicker(w.failbackInterval())
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			if err := w.probeSource(ctx, w.sources()[0]); err != nil {
+				continue
+			}
+			return errSourceFailback
+		}
+	}
+}
+
+// probeSource - Kết nối thử 1 source, thành công khi nhận được 1 frame RTCM hợp lệ
+func (w *Worker) probeSource(ctx context.Context, src ConfigSource) error {
+	ctx, cancel := context.WithTimeout(ctx, FailbackProbeTimeout)
+	defer cancel()
+
+	conn, reader, resp, err := w.openSource(ctx, src, true)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	lat, lon, _ := w.ggaPosition()
+	conn.Write([]byte(generateNMEA(lat, lon, false)))
+
+	conn.SetReadDeadline(time.Now().Add(FailbackProbeTimeout))
+	var crcErrors, dropped int64
+	_, err = newRTCMFramer(sourceBodyReader(reader, resp), &crcErrors, &dropped).Next()
+	return err
+}
@@ -0,0 +1,540 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ================= STATION HISTORY =================
+// Bộ đếm của worker reset mỗi lần restart nên không trả lời được "tuần trước station X mất bao lâu".
+// historyStore lấy mẫu mọi worker mỗi interval_s giây: lượng bytes/frames/CRC tăng thêm trong khoảng,
+// số giây ở trạng thái Running, số lần rời Running và số sự kiện lỗi theo loại.
+// Mẫu ghi nối tiếp vào file JSON lines theo ngày (UTC):
+//
+//	history/<station>/2026-10-16.jsonl
+//
+// Quá raw_days ngày thì file được gộp theo downsample_s giây, quá retention_days ngày thì xoá.
+const (
+	DefaultHistoryDir        = "history"
+	DefaultHistoryInterval   = 60  // Giây giữa 2 lần lấy mẫu
+	DefaultHistoryRetention  = 90  // Ngày giữ lịch sử
+	DefaultHistoryRawDays    = 7   // Ngày giữ mẫu gốc, cũ hơn thì gộp
+	DefaultHistoryDownsample = 900 // Giây mỗi mẫu sau khi gộp
+	HistoryMaintainInterval  = time.Hour
+	MaxHistoryPoints         = 2000 // Số điểm tối đa API trả về (tự tăng step)
+)
+
+type HistorySettings struct {
+	Enable        bool   `json:"enable"`
+	Dir           string `json:"dir"`
+	IntervalS     int    `json:"interval_s"`
+	RetentionDays int    `json:"retention_days"`
+	RawDays       int    `json:"raw_days"`
+	DownsampleS   int    `json:"downsample_s"`
+}
+
+// historySample - 1 dòng trong file lịch sử, phủ khoảng [T, T+Span)
+type historySample struct {
+	T      int64 `json:"t"` // Unix giây
+	Span   int64 `json:"s"` // Độ dài khoảng (giây)
+	Bytes  int64 `json:"b"`
+	Frames int64 `json:"f"`
+	CRC    int64 `json:"c,omitempty"`
+	Up     int64 `json:"u"`           // Số giây ở trạng thái Running
+	Epochs int64 `json:"e,omitempty"` // Số giây có epoch quan sát RTCM
+	// Đếm lúc lấy mẫu nên cộng được khi gộp (downsample), báo cáo SLA không phải đoán sự cố từ chuỗi mẫu
+	Outages int64            `json:"o,omitempty"` // Số lần rời Running
+	Causes  map[string]int64 `json:"x,omitempty"` // Sự kiện lỗi theo loại (auth_failed, stale...)
+}
+
+func (s *historySample) add(o historySample) {
+	s.Span += o.Span
+	s.Bytes += o.Bytes
+	s.Frames += o.Frames
+	s.CRC += o.CRC
+	s.Up += o.Up
+	s.Epochs += o.Epochs
+	s.Outages += o.Outages
+	for k, v := range o.Causes {
+		if s.Causes == nil {
+			s.Causes = make(map[string]int64)
+		}
+		s.Causes[k] += v
+	}
+}
+
+type historyCounters struct {
+	bytes, frames, crc, epochs, outages int64
+	causes                              [len(errorEventTypes)]int64
+}
+
+type historyStore struct {
+	cfg HistorySettings
+
+	mu   sync.Mutex // 1 lần lấy mẫu / bảo trì tại 1 thời điểm
+	last time.Time
+	prev map[*Worker]historyCounters // Bộ đếm lúc lấy mẫu trước (worker mới bắt đầu từ 0)
+}
+
+var history *historyStore // nil = tắt (settings.json "history")
+
+// newHistoryStore - Giá trị <= 0 trong settings dùng mặc định
+func newHistoryStore(cfg HistorySettings) *historyStore {
+	def := defaultSettings().History
+	if cfg.Dir == "" {
+		cfg.Dir = def.Dir
+	}
+	if cfg.IntervalS <= 0 {
+		cfg.IntervalS = def.IntervalS
+	}
+	if cfg.RetentionDays <= 0 {
+		cfg.RetentionDays = def.RetentionDays
+	}
+	if cfg.RawDays <= 0 {
+		cfg.RawDays = def.RawDays
+	}
+	if cfg.DownsampleS <= 0 {
+		cfg.DownsampleS = def.DownsampleS
+	}
+	return &historyStore{cfg: cfg, last: time.Now(), prev: make(map[*Worker]historyCounters)}
+}
+
+// run - Lấy mẫu theo interval_s, bảo trì (gộp/xoá file cũ) mỗi giờ
+func (h *historyStore) run() {
+	h.maintain(time.Now())
+	sampleTicker := time.NewTicker(time.Duration(h.cfg.IntervalS) * time.Second)
+	maintainTicker := time.NewTicker(HistoryMaintainInterval)
+	for {
+		select {
+		case now := <-sampleTicker.C:
+			h.sample(now)
+		case now := <-maintainTicker.C:
+			h.maintain(now)
+		}
+	}
+}
+
+// sample - Ghi 1 mẫu cho mọi worker đang chạy (gọi thêm 1 lần khi tắt chương trình)
+func (h *historyStore) sample(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	manager.mu.RLock()
+	workers := make([]*Worker, 0, len(manager.workers))
+	for _, w := range manager.workers {
+		workers = append(workers, w)
+	}
+	manager.mu.RUnlock()
+
+	next := make(map[*Worker]historyCounters, len(workers))
+	for _, w := range workers {
+		snap := w.statusSnapshot()
+		// Worker tạo sau lần lấy mẫu trước: khoảng bắt đầu từ lúc tạo worker
+		from := h.last
+		if hist := snap.StateHistory; len(hist) > 0 && hist[0].From == StateNotStarted.String() && hist[0].Time.After(from) {
+			from = hist[0].Time
+		}
+		c := historyCounters{
+			bytes:   atomic.LoadInt64(&w.counters.BytesForwarded),
+			frames:  atomic.LoadInt64(&w.counters.FramesForwarded),
+			crc:     atomic.LoadInt64(&w.counters.CRCErrors),
+			epochs:  atomic.LoadInt64(&w.counters.EpochSeconds),
+			outages: snap.outages,
+		}
+		for i := range c.causes {
+			c.causes[i] = atomic.LoadInt64(&w.counters.Causes[i])
+		}
+		p := h.prev[w]
+		next[w] = c
+		s := historySample{
+			T:       from.Unix(),
+			Span:    int64(now.Sub(from).Round(time.Second).Seconds()),
+			Bytes:   c.bytes - p.bytes,
+			Frames:  c.frames - p.frames,
+			CRC:     c.crc - p.crc,
+			Epochs:  c.epochs - p.epochs,
+			Up:      int64(runningTime(snap, from, now).Round(time.Second).Seconds()),
+			Outages: c.outages - p.outages,
+		}
+		for i, t := range errorEventTypes {
+			if n := c.causes[i] - p.causes[i]; n > 0 {
+				if s.Causes == nil {
+					s.Causes = make(map[string]int64)
+				}
+				s.Causes[string(t)] = n
+			}
+		}
+		if err := h.append(w.cfg.ID, s); err != nil {
+			log.Printf("[History] ❌ Write %s failed: %v", w.cfg.ID, err)
+		}
+	}
+	h.prev = next
+	h.last = now
+}
+
+// runningTime - Thời gian ở trạng thái Running trong [from, to), tính từ lịch sử chuyển trạng thái
+func runningTime(s StationStatus, from, to time.Time) time.Duration {
+	running := StateRunning.String()
+	hist := s.StateHistory
+	if len(hist) == 0 {
+		if s.Status == running {
+			return to.Sub(from)
+		}
+		return 0
+	}
+
+	// Trạng thái tại from
+	state := hist[0].From
+	for _, ch := range hist {
+		if ch.Time.After(from) {
+			break
+		}
+		state = ch.To
+	}
+
+	total := time.Duration(0)
+	t := from
+	for _, ch := range hist {
+		if !ch.Time.After(from) {
+			continue
+		}
+		if !ch.Time.Before(to) {
+			break
+		}
+		if state == running {
+			total += ch.Time.Sub(t)
+		}
+		t, state = ch.Time, ch.To
+	}
+	if state == running {
+		total += to.Sub(t)
+	}
+	return total
+}
+
+func (h *historyStore) stationDir(id string) string {
+	return filepath.Join(h.cfg.Dir, safeFileName(id))
+}
+
+// safeFileName - Tên file/thư mục theo station ID: giữ [A-Za-z0-9_.-], ký tự khác thành %XX (an toàn cả trên Windows)
+func safeFileName(id string) string {
+	var b strings.Builder
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-' || c == '.' && i > 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func dayFile(t time.Time) string {
+	return t.UTC().Format(time.DateOnly) + ".jsonl"
+}
+
+func (h *historyStore) append(id string, s historySample) error {
+	dir := h.stationDir(id)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	line, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, dayFile(time.Unix(s.T, 0))), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(line, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// readDay - Đọc các mẫu trong 1 file ngày (file chưa có = không có mẫu, dòng hỏng bị bỏ qua)
+func readDay(path string) ([]historySample, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var samples []historySample
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var s historySample
+		if json.Unmarshal(scanner.Bytes(), &s) == nil {
+			samples = append(samples, s)
+		}
+	}
+	return samples, scanner.Err()
+}
+
+// maintain - Xoá file quá retention_days, gộp file quá raw_days theo downsample_s.
+// File đã gộp có ModTime sau (cuối ngày + raw_days) nên không bị gộp lại lần nữa.
+func (h *historyStore) maintain(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	stations, err := os.ReadDir(h.cfg.Dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[History] ❌ Read %s failed: %v", h.cfg.Dir, err)
+		}
+		return
+	}
+	expire := now.AddDate(0, 0, -h.cfg.RetentionDays)
+	rawLimit := time.Duration(h.cfg.RawDays) * 24 * time.Hour
+	removed, compacted := 0, 0
+	for _, st := range stations {
+		if !st.IsDir() {
+			continue
+		}
+		dir := filepath.Join(h.cfg.Dir, st.Name())
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, f := range files {
+			day, err := time.Parse(time.DateOnly, strings.TrimSuffix(f.Name(), ".jsonl"))
+			if err != nil {
+				continue
+			}
+			dayEnd := day.AddDate(0, 0, 1)
+			path := filepath.Join(dir, f.Name())
+			if dayEnd.Before(expire) {
+				if os.Remove(path) == nil {
+					removed++
+				}
+				continue
+			}
+			info, err := f.Info()
+			if err != nil || now.Sub(dayEnd) < rawLimit || info.ModTime().Sub(dayEnd) >= rawLimit {
+				continue
+			}
+			if err := compactDay(path, int64(h.cfg.DownsampleS)); err != nil {
+				log.Printf("[History] ❌ Downsample %s failed: %v", path, err)
+				continue
+			}
+			compacted++
+		}
+	}
+	if removed > 0 || compacted > 0 {
+		log.Printf("[History] Maintenance: %d file(s) expired, %d file(s) downsampled", removed, compacted)
+	}
+}
+
+// compactDay - Gộp các mẫu trong file theo step giây (ghi file tạm rồi rename)
+func compactDay(path string, step int64) error {
+	samples, err := readDay(path)
+	if err != nil {
+		return err
+	}
+	var merged []historySample
+	for _, s := range samples {
+		t := s.T - s.T%step
+		if n := len(merged); n > 0 && merged[n-1].T == t {
+			merged[n-1].add(s)
+			continue
+		}
+		s.T = t
+		merged = append(merged, s)
+	}
+
+	var buf strings.Builder
+	for _, s := range merged {
+		line, _ := json.Marshal(s)
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(buf.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ================= HISTORY API =================
+type HistoryPoint struct {
+	Time         time.Time `json:"t"`
+	Bytes        int64     `json:"bytes"`
+	Frames       int64     `json:"frames"`
+	CRCErrors    int64     `json:"crc_errors"`
+	UpSeconds    int64     `json:"up_s"`
+	Sampled      int64     `json:"sampled_s"`              // Số giây có mẫu (relay đang chạy)
+	Availability *float64  `json:"availability,omitempty"` // up_s / sampled_s, không có nếu chưa có mẫu
+}
+
+type HistorySummary struct {
+	Bytes        int64    `json:"bytes"`
+	Frames       int64    `json:"frames"`
+	CRCErrors    int64    `json:"crc_errors"`
+	UpSeconds    int64    `json:"up_s"`
+	DownSeconds  int64    `json:"down_s"`    // Relay chạy nhưng station không Running
+	NoDataSecond int64    `json:"no_data_s"` // Không có mẫu (relay tắt, station chưa tạo/đã tắt)
+	Availability *float64 `json:"availability,omitempty"`
+}
+
+type HistoryResponse struct {
+	ID      string         `json:"id"`
+	From    time.Time      `json:"from"`
+	To      time.Time      `json:"to"`
+	Step    int64          `json:"step_s"`
+	Points  []HistoryPoint `json:"points"`
+	Summary HistorySummary `json:"summary"`
+}
+
+func availability(up, sampled int64) *float64 {
+	if sampled <= 0 {
+		return nil
+	}
+	a := min(float64(up)/float64(sampled), 1)
+	return &a
+}
+
+// samples - Các mẫu của station có thời điểm bắt đầu trong [from, to), theo thứ tự thời gian
+func (h *historyStore) samples(id string, from, to time.Time) ([]historySample, error) {
+	var res []historySample
+	dir := h.stationDir(id)
+	for day := from.UTC().Truncate(24 * time.Hour); day.Before(to); day = day.AddDate(0, 0, 1) {
+		samples, err := readDay(filepath.Join(dir, dayFile(day)))
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range samples {
+			if s.T >= from.Unix() && s.T < to.Unix() {
+				res = append(res, s)
+			}
+		}
+	}
+	return res, nil
+}
+
+// query - Gộp các mẫu trong [from, to) theo step giây (mẫu tính vào bucket chứa thời điểm đầu của nó)
+func (h *historyStore) query(id string, from, to time.Time, step int64) (HistoryResponse, error) {
+	res := HistoryResponse{ID: id, From: from, To: to, Step: step}
+	n := (to.Unix() - from.Unix() + step - 1) / step
+	res.Points = make([]HistoryPoint, n)
+	for i := range res.Points {
+		res.Points[i].Time = from.Add(time.Duration(int64(i)*step) * time.Second)
+	}
+
+	samples, err := h.samples(id, from, to)
+	if err != nil {
+		return res, err
+	}
+	for _, s := range samples {
+		p := &res.Points[(s.T-from.Unix())/step]
+		p.Bytes += s.Bytes
+		p.Frames += s.Frames
+		p.CRCErrors += s.CRC
+		p.UpSeconds += s.Up
+		p.Sampled += s.Span
+	}
+
+	sampled := int64(0)
+	for i := range res.Points {
+		p := &res.Points[i]
+		p.Availability = availability(p.UpSeconds, p.Sampled)
+		res.Summary.Bytes += p.Bytes
+		res.Summary.Frames += p.Frames
+		res.Summary.CRCErrors += p.CRCErrors
+		res.Summary.UpSeconds += p.UpSeconds
+		sampled += p.Sampled
+	}
+	res.Summary.DownSeconds = max(sampled-res.Summary.UpSeconds, 0)
+	res.Summary.NoDataSecond = max(to.Unix()-from.Unix()-sampled, 0)
+	res.Summary.Availability = availability(res.Summary.UpSeconds, sampled)
+	return res, nil
+}
+
+// parseHistoryTime - Unix giây hoặc RFC3339
+func parseHistoryTime(v string, def time.Time) (time.Time, error) {
+	if v == "" {
+		return def, nil
+	}
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return time.Unix(sec, 0), nil
+	}
+	return time.Parse(time.RFC3339, v)
+}
+
+// parseHistoryStep - Số giây hoặc duration Go ("5m", "1h")
+func parseHistoryStep(v string) (int64, error) {
+	if sec, err := strconv.ParseInt(v, 10, 64); err == nil {
+		return sec, nil
+	}
+	d, err := time.ParseDuration(v)
+	return int64(d.Seconds()), err
+}
+
+// handleStationHistory - GET /api/stations/{id}/history?from=&to=&step=
+// from/to: Unix giây hoặc RFC3339 (mặc định 24h gần nhất), step: giây hoặc "5m" (mặc định tự chọn)
+func handleStationHistory(w http.ResponseWriter, r *http.Request, id string) {
+	if history == nil {
+		http.Error(w, "History is disabled", http.StatusNotFound)
+		return
+	}
+
+	q := r.URL.Query()
+	now := time.Now()
+	to, err := parseHistoryTime(q.Get("to"), now)
+	if err != nil {
+		http.Error(w, "Invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := parseHistoryTime(q.Get("from"), to.Add(-24*time.Hour))
+	if err != nil {
+		http.Error(w, "Invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to = minTime(to, now)
+	if !from.Before(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+
+	span := to.Unix() - from.Unix()
+	step := int64(history.cfg.IntervalS)
+	if v := q.Get("step"); v != "" {
+		if step, err = parseHistoryStep(v); err != nil || step <= 0 {
+			http.Error(w, "Invalid step", http.StatusBadRequest)
+			return
+		}
+	} else {
+		step = max(step, span/120) // Mặc định ~120 điểm (sparkline)
+	}
+	step = max(step, (span+MaxHistoryPoints-1)/MaxHistoryPoints)
+
+	// Căn from theo step để các lần gọi liên tiếp ra cùng bucket
+	from = time.Unix(from.Unix()-from.Unix()%step, 0)
+	res, err := history.query(id, from, to, step)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(res)
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
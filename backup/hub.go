@@ -0,0 +1,96 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// ================= BROADCAST HUB =================
+// Mỗi station (mountpoint) có 1 luồng RTCM. Worker publish các frame đã kiểm tra CRC vào hub,
+// các subscriber (rover kết nối vào caster nội bộ, destination của chính station và các station
+// khai báo src_ref...) nhận qua channel riêng.
+// Publish không bao giờ block: subscriber chậm bị bỏ frame (đếm vào dropped).
+const HubSubscriberBuffer = 256 // Số lô frame tối đa xếp hàng cho mỗi subscriber
+
+type hubSubscriber struct {
+	mount   string
+	C       chan []byte
+	dropped int64  // Số lô frame bị bỏ vì subscriber không đọc kịp (atomic)
+	total   *int64 // Bộ đếm cộng dồn bên ngoài (VD: status của worker qua nhiều session), có thể nil
+	once    sync.Once
+	hub     *streamHub
+}
+
+type streamHub struct {
+	mu     sync.RWMutex
+	mounts map[string]map[*hubSubscriber]struct{}
+}
+
+var hub = &streamHub{
+	mounts: make(map[string]map[*hubSubscriber]struct{}),
+}
+
+// subscribe - Đăng ký nhận dữ liệu của 1 mountpoint.
+// total (có thể nil) được cộng thêm mỗi khi subscriber bị bỏ 1 lô frame.
+func (h *streamHub) subscribe(mount string, total *int64) *hubSubscriber {
+	sub := &hubSubscriber{
+		mount: mount,
+		C:     make(chan []byte, HubSubscriberBuffer),
+		total: total,
+		hub:   h,
+	}
+	h.mu.Lock()
+	subs, ok := h.mounts[mount]
+	if !ok {
+		subs = make(map[*hubSubscriber]struct{})
+		h.mounts[mount] = subs
+	}
+	subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+// close - Huỷ đăng ký, an toàn khi gọi nhiều lần
+func (s *hubSubscriber) close() {
+	s.once.Do(func() {
+		s.hub.mu.Lock()
+		if subs, ok := s.hub.mounts[s.mount]; ok {
+			delete(subs, s)
+			if len(subs) == 0 {
+				delete(s.hub.mounts, s.mount)
+			}
+		}
+		s.hub.mu.Unlock()
+	})
+}
+
+// publish - Gửi 1 lô frame tới mọi subscriber của mountpoint (không block)
+func (h *streamHub) publish(mount string, data []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	subs := h.mounts[mount]
+	if len(subs) == 0 {
+		return
+	}
+	// Copy 1 lần, các subscriber dùng chung (chỉ đọc)
+	msg := make([]byte, len(data))
+	copy(msg, data)
+	for sub := range subs {
+		select {
+		case sub.C <- msg:
+		default:
+			atomic.AddInt64(&sub.dropped, 1)
+			if sub.total != nil {
+				atomic.AddInt64(sub.total, 1)
+			}
+		}
+	}
+}
+
+// subscriberCount - Số subscriber đang nghe 1 mountpoint
+func (h *streamHub) subscriberCount(mount string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.mounts[mount])
+}
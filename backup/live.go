@@ -0,0 +1,52 @@
+package main
+
+// ================= LIVE CONFIG =================
+// Trường config không ảnh hưởng tới kết nối (vị trí GGA, ngưỡng cảnh báo, ngưỡng failover...)
+// được áp dụng ngay vào worker đang chạy khi reload, không restart session.
+// Các trường còn lại (host, port, mount, tài khoản, proxy, TLS...) đổi thì mới phải kết nối lại.
+
+// LiveSettings - Phần config đổi được khi đang chạy. Worker đọc qua w.settings() (atomic).
+type LiveSettings struct {
+	Lat              float64
+	Lon              float64
+	PosWarnDistance  float64
+	UseRTCMPosition  bool
+	FailoverAfter    int
+	FailoverNoData   int
+	FailbackInterval int
+}
+
+func liveSettingsOf(cfg ConfigStation) *LiveSettings {
+	return &LiveSettings{
+		Lat:              cfg.Lat,
+		Lon:              cfg.Lon,
+		PosWarnDistance:  cfg.PosWarnDistance,
+		UseRTCMPosition:  cfg.UseRTCMPosition,
+		FailoverAfter:    cfg.FailoverAfter,
+		FailoverNoData:   cfg.FailoverNoData,
+		FailbackInterval: cfg.FailbackInterval,
+	}
+}
+
+// connectionHash - Hash config bỏ qua các trường live: chỉ đổi khi cần kết nối lại
+func connectionHash(cfg ConfigStation) string {
+	cfg.Lat, cfg.Lon = 0, 0
+	cfg.PosWarnDistance = 0
+	cfg.UseRTCMPosition = false
+	cfg.FailoverAfter, cfg.FailoverNoData, cfg.FailbackInterval = 0, 0, 0
+	return getMD5Hash(cfg)
+}
+
+func (w *Worker) settings() *LiveSettings {
+	return w.live.Load()
+}
+
+// applyLive - Đẩy phần config live mới vào worker đang chạy, trả về false nếu không có gì đổi
+func (w *Worker) applyLive(cfg ConfigStation) bool {
+	next := liveSettingsOf(cfg)
+	if *next == *w.settings() {
+		return false
+	}
+	w.live.Store(next)
+	return true
+}
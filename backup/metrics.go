@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// ================= PROMETHEUS METRICS =================
+// /metrics xuất số liệu theo định dạng text của Prometheus (viết tay, không cần client_golang).
+// Số liệu lấy trực tiếp từ worker đang chạy (bộ đếm atomic, statusSnapshot), không đọc lại config.json.
+// Series theo station có label station="<id>"; số liệu toàn hệ thống không có label.
+
+// latencyBuckets - Ngưỡng (giây) của histogram độ trễ dial / bắt tay NTRIP
+var latencyBuckets = [...]float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+
+// latencyHistogram - Histogram độ trễ, chỉ dùng atomic (counts không cộng dồn, cộng dồn khi xuất)
+type latencyHistogram struct {
+	counts [len(latencyBuckets) + 1]int64 // Phần tử cuối: lớn hơn bucket lớn nhất (+Inf)
+	count  int64
+	sumNs  int64
+}
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	i := sort.SearchFloat64s(latencyBuckets[:], d.Seconds())
+	atomic.AddInt64(&h.counts[i], 1)
+	atomic.AddInt64(&h.count, 1)
+	atomic.AddInt64(&h.sumNs, d.Nanoseconds())
+}
+
+// stationLatency - Độ trễ kết nối của worker: dial (TCP + proxy + TLS) và bắt tay NTRIP (request -> response)
+type stationLatency struct {
+	sourceDial      latencyHistogram
+	destDial        latencyHistogram
+	sourceHandshake latencyHistogram
+	destHandshake   latencyHistogram
+}
+
+// Lý do session kết thúc (label reason của relay_station_reconnects_total)
+const (
+	ReasonAuth = iota
+	ReasonMountNotFound
+	ReasonSourcetable
+	ReasonRejected
+	ReasonClosedOnConnect
+	ReasonTLS
+	ReasonProxyAuth
+	ReasonUnavailable
+	ReasonDNS
+	ReasonProxy
+	ReasonRefused
+	ReasonTimeout
+	ReasonClosed
+	ReasonStale
+	ReasonNetwork
+	ReasonFailback
+	ReasonUnknown
+	numReasons
+)
+
+var reasonNames = [numReasons]string{
+	ReasonAuth:            "auth",
+	ReasonMountNotFound:   "mount_not_found",
+	ReasonSourcetable:     "sourcetable",
+	ReasonRejected:        "rejected",
+	ReasonClosedOnConnect: "closed_on_connect",
+	ReasonTLS:             "tls",
+	ReasonProxyAuth:       "proxy_auth",
+	ReasonUnavailable:     "unavailable",
+	ReasonDNS:             "dns",
+	ReasonProxy:           "proxy",
+	ReasonRefused:         "refused",
+	ReasonTimeout:         "timeout",
+	ReasonClosed:          "closed",
+	ReasonStale:           "stale",
+	ReasonNetwork:         "network",
+	ReasonFailback:        "failback",
+	ReasonUnknown:         "unknown",
+}
+
+var reasonKinds = []struct {
+	kind   error
+	reason int
+}{
+	{ErrAuth, ReasonAuth},
+	{ErrMountNotFound, ReasonMountNotFound},
+	{ErrSourcetableReturned, ReasonSourcetable},
+	{ErrRejected, ReasonRejected},
+	{ErrClosedOnConnect, ReasonClosedOnConnect},
+	{ErrTLS, ReasonTLS},
+	{ErrProxyAuth, ReasonProxyAuth},
+	{ErrUnavailable, ReasonUnavailable},
+	{ErrDNS, ReasonDNS},
+	{ErrProxy, ReasonProxy},
+	{ErrRefused, ReasonRefused},
+	{ErrTimeout, ReasonTimeout},
+	{ErrClosed, ReasonClosed},
+	{ErrStale, ReasonStale},
+	{ErrNetwork, ReasonNetwork},
+	{errSourceFailback, ReasonFailback},
+}
+
+// reconnectReason - Phân loại lỗi kết thúc session thành label reason
+func reconnectReason(err error) int {
+	for _, k := range reasonKinds {
+		if errors.Is(err, k.kind) {
+			return k.reason
+		}
+	}
+	return ReasonUnknown
+}
+
+// metricsWriter - Ghi từng metric family: HELP/TYPE 1 lần rồi tới các series
+type metricsWriter struct {
+	w *bufio.Writer
+}
+
+func (m metricsWriter) family(name, typ, help string) {
+	fmt.Fprintf(m.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample - labels là cặp key, value liên tiếp
+func (m metricsWriter) sample(name string, value float64, labels ...string) {
+	m.w.WriteString(name)
+	if len(labels) > 0 {
+		m.w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				m.w.WriteByte(',')
+			}
+			fmt.Fprintf(m.w, "%s=\"%s\"", labels[i], escapeLabel(labels[i+1]))
+		}
+		m.w.WriteByte('}')
+	}
+	fmt.Fprintf(m.w, " %g\n", value)
+}
+
+func (m metricsWriter) histogram(name string, h *latencyHistogram, labels ...string) {
+	cumulative := int64(0)
+	for i, le := range latencyBuckets {
+		cumulative += atomic.LoadInt64(&h.counts[i])
+		m.sample(name+"_bucket", float64(cumulative), append(labels, "le", fmt.Sprintf("%g", le))...)
+	}
+	count := atomic.LoadInt64(&h.count)
+	m.sample(name+"_bucket", float64(count), append(labels, "le", "+Inf")...)
+	m.sample(name+"_sum", time.Duration(atomic.LoadInt64(&h.sumNs)).Seconds(), labels...)
+	m.sample(name+"_count", float64(count), labels...)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
+
+// handleMetrics - GET /metrics (Prometheus text format 0.0.4)
+func handleMetrics(w http.ResponseWriter, r *http.Request) {
+	manager.mu.RLock()
+	workers := make([]*Worker, 0, len(manager.workers))
+	for _, worker := range manager.workers {
+		workers = append(workers, worker)
+	}
+	manager.mu.RUnlock()
+	sort.Slice(workers, func(i, j int) bool { return workers[i].cfg.ID < workers[j].cfg.ID })
+
+	now := time.Now()
+	statuses := make([]StationStatus, len(workers))
+	for i, worker := range workers {
+		statuses[i] = worker.statusSnapshot()
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+	m := metricsWriter{bw}
+
+	// --- Toàn hệ thống ---
+	m.family("relay_workers", "gauge", "Number of running station workers.")
+	m.sample("relay_workers", float64(len(workers)))
+	m.family("relay_goroutines", "gauge", "Number of goroutines in the relay process.")
+	m.sample("relay_goroutines", float64(runtime.NumGoroutine()))
+	if manager.dials != nil {
+		m.family("relay_dial_queue_depth", "gauge", "Workers waiting for a dial slot.")
+		m.sample("relay_dial_queue_depth", float64(atomic.LoadInt64(&manager.dials.queued)))
+		m.family("relay_dial_in_flight", "gauge", "Dials currently holding a concurrency slot.")
+		m.sample("relay_dial_in_flight", float64(len(manager.dials.sem)))
+	}
+	if caster != nil {
+		m.family("relay_caster_clients", "gauge", "Rovers connected to the built-in caster.")
+		m.sample("relay_caster_clients", float64(len(caster.clientList())))
+	}
+
+	// --- Bộ đếm theo station ---
+	counters := []struct {
+		name, help string
+		value      func(s *StationStatus) int64
+	}{
+		{"relay_station_bytes_forwarded_total", "Bytes forwarded to the destination.", func(s *StationStatus) int64 { return s.BytesForwarded }},
+		{"relay_station_frames_forwarded_total", "Valid RTCM3 frames forwarded.", func(s *StationStatus) int64 { return s.FramesForwarded }},
+		{"relay_station_crc_errors_total", "RTCM3 frames dropped for bad CRC-24Q.", func(s *StationStatus) int64 { return s.CRCErrors }},
+		{"relay_station_bytes_dropped_total", "Garbage bytes dropped between RTCM3 frames.", func(s *StationStatus) int64 { return s.BytesDropped }},
+		{"relay_station_dest_dropped_total", "Frame batches dropped because the destination was slow.", func(s *StationStatus) int64 { return s.DestDropped }},
+	}
+	for _, c := range counters {
+		m.family(c.name, "counter", c.help)
+		for i := range statuses {
+			m.sample(c.name, float64(c.value(&statuses[i])), "station", statuses[i].ID)
+		}
+	}
+
+	m.family("relay_station_sessions_total", "counter", "Sessions started (connection attempts).")
+	for _, worker := range workers {
+		m.sample("relay_station_sessions_total", float64(atomic.LoadInt64(&worker.counters.Sessions)), "station", worker.cfg.ID)
+	}
+
+	m.family("relay_station_reconnects_total", "counter", "Sessions that ended with an error, by reason.")
+	for _, worker := range workers {
+		for reason, name := range reasonNames {
+			if n := atomic.LoadInt64(&worker.counters.Reconnects[reason]); n > 0 {
+				m.sample("relay_station_reconnects_total", float64(n), "station", worker.cfg.ID, "reason", name)
+			}
+		}
+	}
+
+	// --- Trạng thái ---
+	m.family("relay_station_state", "gauge", "Current station state (1 for the active state).")
+	for i := range statuses {
+		for state := range stateNames {
+			value := 0.0
+			if statuses[i].state == StationState(state) {
+				value = 1
+			}
+			m.sample("relay_station_state", value, "station", statuses[i].ID, "state", stateNames[state])
+		}
+	}
+
+	m.family("relay_station_last_data_age_seconds", "gauge", "Seconds since a valid RTCM frame was last received from the source.")
+	for _, worker := range workers {
+		if last := atomic.LoadInt64(&worker.lastFrameTime); last > 0 {
+			m.sample("relay_station_last_data_age_seconds", float64(now.Unix()-last), "station", worker.cfg.ID)
+		}
+	}
+
+	// --- Độ trễ kết nối ---
+	m.family("relay_station_dial_seconds", "histogram", "Time to dial the upstream (TCP, proxy and TLS), excluding dial queue wait.")
+	for _, worker := range workers {
+		m.histogram("relay_station_dial_seconds", &worker.latency.sourceDial, "station", worker.cfg.ID, "target", "source")
+		m.histogram("relay_station_dial_seconds", &worker.latency.destDial, "station", worker.cfg.ID, "target", "dest")
+	}
+	m.family("relay_station_handshake_seconds", "histogram", "Time from sending the NTRIP request to a successful caster response.")
+	for _, worker := range workers {
+		m.histogram("relay_station_handshake_seconds", &worker.latency.sourceHandshake, "station", worker.cfg.ID, "target", "source")
+		m.histogram("relay_station_handshake_seconds", &worker.latency.destHandshake, "station", worker.cfg.ID, "target", "dest")
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// addTestWorker - Đưa worker (chưa chạy) vào manager để API/metrics/alert thấy, tự gỡ khi test xong
+func addTestWorker(t testing.TB, w *Worker) {
+	manager.mu.Lock()
+	manager.workers[w.cfg.ID] = w
+	manager.mu.Unlock()
+	t.Cleanup(func() {
+		manager.mu.Lock()
+		delete(manager.workers, w.cfg.ID)
+		manager.mu.Unlock()
+	})
+}
+
+// TestLastDataAgeIgnoresNMEARefresh - Luồng NMEA làm mới lastDataTime sau mỗi GGA,
+// gauge phải tính theo frame RTCM cuối chứ không theo đó
+func TestLastDataAgeIgnoresNMEARefresh(t *testing.T) {
+	w := newWorker(testStation("M1", 1), "", 0)
+	addTestWorker(t, w)
+
+	w.inspectFrame(testFrame(1077))
+	if atomic.LoadInt64(&w.lastFrameTime) == 0 {
+		t.Fatal("inspectFrame did not record the frame time")
+	}
+	now := time.Now().Unix()
+	atomic.StoreInt64(&w.lastFrameTime, now-120)
+	atomic.StoreInt64(&w.lastDataTime, now) // GGA vừa gửi
+
+	rec := httptest.NewRecorder()
+	handleMetrics(rec, httptest.NewRequest("GET", "/metrics", nil))
+	match := regexp.MustCompile(`(?m)^relay_station_last_data_age_seconds\{station="M1"\} (\S+)$`).FindStringSubmatch(rec.Body.String())
+	if match == nil {
+		t.Fatalf("last_data_age sample missing:\n%s", rec.Body.String())
+	}
+	if age, _ := strconv.ParseFloat(match[1], 64); age < 120 || age > 121 {
+		t.Errorf("last_data_age = %v, want ~120", age)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ================= NTRIP RESPONSE PARSER =================
+const MaxRedirects = 3 // Số lần đi theo redirect 3xx tối đa cho 1 lần kết nối
+
+// Caster NTRIP 1.0 trả "ICY 200 OK" (không header), NTRIP 2.0 trả response HTTP/1.1
+// đầy đủ header (Content-Type, Transfer-Encoding, Ntrip-Version...).
+type ntripResponse struct {
+	StatusLine string      // Dòng đầu nguyên bản (đã trim)
+	Proto      string      // "HTTP/1.1", "HTTP/1.0", "ICY", "SOURCETABLE" ("" nếu không nhận ra)
+	Code       int         // Mã trạng thái (0 nếu không có, VD: "OK" của SOURCE NTRIP 1.0)
+	Reason     string      // Phần sau mã trạng thái ("OK", "Unauthorized"...) hoặc cả dòng nếu không có mã
+	Header     http.Header // Header đã chuẩn hoá key (rỗng với ICY)
+}
+
+// readResponse - Đọc dòng trạng thái + toàn bộ header (đến dòng trắng)
+func readResponse(reader *bufio.Reader, conn net.Conn) (*ntripResponse, error) {
+	// Timeout cho việc đọc header response
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	defer conn.SetReadDeadline(time.Time{})
+
+	tp := textproto.NewReader(reader)
+	line, err := tp.ReadLine()
+	if err != nil {
+		if err == io.EOF {
+			return nil, wrapError(ErrClosedOnConnect, fmt.Errorf("server closed immediately (EOF) - Check credentials/mountpoint"))
+		}
+		return nil, classifyNetError(err)
+	}
+
+	resp := &ntripResponse{
+		StatusLine: strings.TrimSpace(line),
+		Header:     make(http.Header),
+	}
+	resp.Proto, resp.Code, resp.Reason = parseStatusLine(resp.StatusLine)
+
+	// ICY 200 OK / OK (NTRIP 1.0) theo chuẩn không có header, body bắt đầu ngay sau dòng trạng thái.
+	// Không chờ thêm: nhiều caster chỉ gửi data sau khi nhận GGA đầu tiên.
+	if resp.Proto == "ICY" || resp.StatusLine == "OK" {
+		if reader.Buffered() == 0 {
+			return resp, nil
+		}
+		if peek, _ := reader.Peek(1); peek[0] != '\r' && peek[0] != '\n' {
+			return resp, nil
+		}
+	}
+
+	// Header lỗi định dạng: giữ những gì đọc được, không chặn session
+	if header, _ := tp.ReadMIMEHeader(); header != nil {
+		resp.Header = http.Header(header)
+	}
+	return resp, nil
+}
+
+// parseStatusLine - Tách dòng trạng thái: "HTTP/1.1 200 OK", "ICY 200 OK", "SOURCETABLE 200 OK",
+// "OK" (SOURCE NTRIP 1.0), "ERROR - Bad Password" (caster NTRIP 1.0 từ chối SOURCE)
+func parseStatusLine(line string) (proto string, code int, reason string) {
+	first, rest, _ := strings.Cut(line, " ")
+	if first != "ICY" && first != "SOURCETABLE" && !strings.HasPrefix(first, "HTTP/") {
+		return "", 0, line
+	}
+	rest = strings.TrimSpace(rest)
+	codeStr, reason, _ := strings.Cut(rest, " ")
+	code, err := strconv.Atoi(codeStr)
+	if err != nil || len(codeStr) != 3 {
+		return first, 0, rest
+	}
+	return first, code, strings.TrimSpace(reason)
+}
+
+// ok - Caster chấp nhận stream ("OK" là phản hồi SOURCE của caster NTRIP 1.0).
+// 200 kèm sourcetable không phải là stream: mountpoint không tồn tại.
+func (r *ntripResponse) ok() bool {
+	if r.Proto == "ICY" || strings.HasPrefix(r.Proto, "HTTP/") {
+		return r.Code == 200 && !r.sourcetable()
+	}
+	return r.Proto == "" && r.StatusLine == "OK"
+}
+
+// sourcetable - Caster trả sourcetable: "SOURCETABLE 200 OK" (NTRIP 1.0) hoặc Content-Type: gnss/sourcetable (NTRIP 2.0)
+func (r *ntripResponse) sourcetable() bool {
+	return r.Proto == "SOURCETABLE" || strings.HasPrefix(strings.ToLower(r.Header.Get("Content-Type")), "gnss/sourcetable")
+}
+
+// ServerInfo - Phản hồi của caster lưu vào status để chẩn đoán (kể cả khi bị từ chối)
+type ServerInfo struct {
+	StatusLine       string    `json:"status_line"`
+	Code             int       `json:"code,omitempty"`
+	Server           string    `json:"server,omitempty"`
+	ContentType      string    `json:"content_type,omitempty"`
+	TransferEncoding string    `json:"transfer_encoding,omitempty"`
+	NtripVersion     string    `json:"ntrip_version,omitempty"`
+	Time             time.Time `json:"time"`
+}
+
+func (r *ntripResponse) info() *ServerInfo {
+	return &ServerInfo{
+		StatusLine:       r.StatusLine,
+		Code:             r.Code,
+		Server:           r.Header.Get("Server"),
+		ContentType:      r.Header.Get("Content-Type"),
+		TransferEncoding: r.Header.Get("Transfer-Encoding"),
+		NtripVersion:     r.Header.Get("Ntrip-Version"),
+		Time:             time.Now(),
+	}
+}
+
+// redirect - Mountpoint đã chuyển sang địa chỉ khác (301/302/303/307/308 kèm Location)
+func (r *ntripResponse) redirect() bool {
+	switch r.Code {
+	case 301, 302, 303, 307, 308:
+		return strings.HasPrefix(r.Proto, "HTTP/") && r.Header.Get("Location") != ""
+	}
+	return false
+}
+
+// retryAfter - Header Retry-After (số giây hoặc HTTP-date), 0 nếu không có
+func (r *ntripResponse) retryAfter() time.Duration {
+	v := strings.TrimSpace(r.Header.Get("Retry-After"))
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// redirectTarget - Đích mới từ Location: URL tuyệt đối (http/https) hoặc đường dẫn tương đối (giữ host cũ)
+func redirectTarget(location, host string, port int, useSSL bool) (string, int, string, bool, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return "", 0, "", false, fmt.Errorf("invalid location %q: %w", location, err)
+	}
+	if u.Host != "" {
+		useSSL = u.Scheme == "https"
+		host = u.Hostname()
+		port = 80
+		if useSSL {
+			port = 443
+		}
+		if p := u.Port(); p != "" {
+			if port, err = strconv.Atoi(p); err != nil {
+				return "", 0, "", false, fmt.Errorf("invalid location %q: bad port", location)
+			}
+		}
+	}
+	mount := strings.TrimPrefix(u.Path, "/")
+	if mount == "" {
+		return "", 0, "", false, fmt.Errorf("location %q has no mountpoint", location)
+	}
+	return host, port, mount, useSSL, nil
+}
+
+// rejectedError - Caster từ chối request (401, 403, 404, trả sourcetable...)
+type rejectedError struct {
+	Code        int
+	StatusLine  string
+	Sourcetable bool          // Caster trả sourcetable thay vì stream
+	RetryAfter  time.Duration // Caster yêu cầu chờ (503/429 + Retry-After)
+}
+
+func (e *rejectedError) Error() string {
+	if e.Sourcetable {
+		return "rejected: " + e.StatusLine + " (caster returned sourcetable - mountpoint not found)"
+	}
+	return "rejected: " + e.StatusLine
+}
+
+// Unwrap - Loại lỗi theo mã trạng thái (errors.Is(err, ErrAuth)...)
+func (e *rejectedError) Unwrap() error {
+	switch {
+	case e.Sourcetable:
+		return ErrSourcetableReturned
+	case e.Code == 0 && strings.Contains(e.StatusLine, "Bad Password"):
+		return ErrAuth // NTRIP 1.0: "ERROR - Bad Password"
+	case e.Code == 401 || e.Code == 403:
+		return ErrAuth
+	case e.Code == 404:
+		return ErrMountNotFound
+	case e.Code >= 500 || e.Code == 429:
+		return ErrUnavailable
+	}
+	return ErrRejected
+}
+
+// chunked - Body dùng Transfer-Encoding: chunked (chỉ có ở NTRIP 2.0 / HTTP/1.1)
+func (r *ntripResponse) chunked() bool {
+	if r.Proto != "HTTP/1.1" {
+		return false
+	}
+	for _, te := range r.Header.Values("Transfer-Encoding") {
+		if strings.Contains(strings.ToLower(te), "chunked") {
+			return true
+		}
+	}
+	return false
+}
+
+// sourceBodyReader - Bỏ lớp chunked (nếu có) để framer chỉ nhìn thấy byte RTCM
+func sourceBodyReader(reader *bufio.Reader, resp *ntripResponse) *bufio.Reader {
+	if !resp.chunked() {
+		return reader
+	}
+	return bufio.NewReaderSize(httputil.NewChunkedReader(reader), BufferSize)
+}
+
+// destBodyWriter - Ghi body lên Dest: mỗi lần Write là 1 chunk nếu request đã khai báo chunked.
+// Trả về hàm close để gửi chunk kết thúc (0\r\n\r\n) trước khi đóng kết nối.
+func destBodyWriter(conn net.Conn, useChunked bool) (io.Writer, func()) {
+	if !useChunked {
+		return conn, func() {}
+	}
+	cw := &chunkedWriter{conn: conn}
+	return cw, func() {
+		conn.SetWriteDeadline(time.Now().Add(2 * time.Second))
+		conn.Write([]byte("0\r\n\r\n"))
+	}
+}
+
+// chunkedWriter - Gộp size + data + CRLF thành 1 lần ghi (tránh 3 gói TCP nhỏ vì NoDelay)
+type chunkedWriter struct {
+	conn net.Conn
+	buf  []byte
+}
+
+func (c *chunkedWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	c.buf = strconv.AppendInt(c.buf[:0], int64(len(p)), 16)
+	c.buf = append(c.buf, '\r', '\n')
+	c.buf = append(c.buf, p...)
+	c.buf = append(c.buf, '\r', '\n')
+	if _, err := c.conn.Write(c.buf); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
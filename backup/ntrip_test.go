@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseStatusLine(t *testing.T) {
+	for _, tc := range []struct {
+		line   string
+		proto  string
+		code   int
+		reason string
+		ok     bool
+	}{
+		{"ICY 200 OK", "ICY", 200, "OK", true},
+		{"SOURCETABLE 200 OK", "SOURCETABLE", 200, "OK", false},
+		{"HTTP/1.1 200 OK", "HTTP/1.1", 200, "OK", true},
+		{"HTTP/1.0 200 OK", "HTTP/1.0", 200, "OK", true},
+		{"HTTP/1.1 401 Unauthorized", "HTTP/1.1", 401, "Unauthorized", false},
+		{"HTTP/1.1 404", "HTTP/1.1", 404, "", false},
+		{"HTTP/1.1 2000 OK", "HTTP/1.1", 0, "2000 OK", false},
+		{"OK", "", 0, "OK", true},
+		{"ERROR - Bad Password", "", 0, "ERROR - Bad Password", false},
+		{"", "", 0, "", false},
+	} {
+		proto, code, reason := parseStatusLine(tc.line)
+		if proto != tc.proto || code != tc.code || reason != tc.reason {
+			t.Errorf("parseStatusLine(%q) = %q, %d, %q; want %q, %d, %q", tc.line, proto, code, reason, tc.proto, tc.code, tc.reason)
+		}
+		r := &ntripResponse{StatusLine: tc.line, Proto: proto, Code: code, Reason: reason, Header: make(http.Header)}
+		if r.ok() != tc.ok {
+			t.Errorf("%q: ok() = %v, want %v", tc.line, r.ok(), tc.ok)
+		}
+	}
+
+	// NTRIP 2.0: 200 kèm Content-Type sourcetable là mountpoint không tồn tại
+	r := &ntripResponse{Proto: "HTTP/1.1", Code: 200, Header: http.Header{"Content-Type": {"gnss/sourcetable"}}}
+	if r.ok() {
+		t.Error("HTTP 200 with gnss/sourcetable accepted as a stream")
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	for _, tc := range []struct {
+		proto    string
+		code     int
+		location string
+		want     bool
+	}{
+		{"HTTP/1.1", 301, "http://b.example/MNT", true},
+		{"HTTP/1.1", 307, "/MNT", true},
+		{"HTTP/1.0", 302, "/MNT", true},
+		{"HTTP/1.1", 302, "", false},
+		{"HTTP/1.1", 304, "/MNT", false},
+		{"ICY", 301, "/MNT", false},
+	} {
+		r := &ntripResponse{Proto: tc.proto, Code: tc.code, Header: make(http.Header)}
+		if tc.location != "" {
+			r.Header.Set("Location", tc.location)
+		}
+		if got := r.redirect(); got != tc.want {
+			t.Errorf("%s %d Location=%q: redirect() = %v, want %v", tc.proto, tc.code, tc.location, got, tc.want)
+		}
+	}
+}
+
+func TestRedirectTarget(t *testing.T) {
+	for _, tc := range []struct {
+		location string
+		host     string
+		port     int
+		mount    string
+		ssl      bool
+		wantErr  bool
+	}{
+		{"http://b.example:2102/NEW", "b.example", 2102, "NEW", false, false},
+		{"http://b.example/NEW", "b.example", 80, "NEW", false, false},
+		{"https://b.example/NEW", "b.example", 443, "NEW", true, false},
+		{"https://[::1]:8443/NEW", "::1", 8443, "NEW", true, false},
+		{"/NEW", "a.example", 2101, "NEW", false, false}, // Tương đối: giữ host/port/SSL cũ
+		{"NEW", "a.example", 2101, "NEW", false, false},
+		{"http://b.example:port/NEW", "", 0, "", false, true},
+		{"http://b.example/", "", 0, "", false, true},
+		{"http://b.example:2102/%zz", "", 0, "", false, true},
+	} {
+		host, port, mount, ssl, err := redirectTarget(tc.location, "a.example", 2101, false)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("redirectTarget(%q) = %s:%d/%s, want error", tc.location, host, port, mount)
+			}
+			continue
+		}
+		if err != nil || host != tc.host || port != tc.port || mount != tc.mount || ssl != tc.ssl {
+			t.Errorf("redirectTarget(%q) = %s, %d, %s, %v, %v; want %s, %d, %s, %v",
+				tc.location, host, port, mount, ssl, err, tc.host, tc.port, tc.mount, tc.ssl)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	for _, tc := range []struct {
+		value    string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"30", 30 * time.Second, 30 * time.Second},
+		{" 5 ", 5 * time.Second, 5 * time.Second},
+		{"0", 0, 0},
+		{"-10", 0, 0},
+		{"soon", 0, 0},
+		{time.Now().Add(2 * time.Minute).UTC().Format(http.TimeFormat), 100 * time.Second, 2 * time.Minute},
+		{time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat), 0, 0}, // Đã qua
+	} {
+		r := &ntripResponse{Header: make(http.Header)}
+		if tc.value != "" {
+			r.Header.Set("Retry-After", tc.value)
+		}
+		if got := r.retryAfter(); got < tc.min || got > tc.max {
+			t.Errorf("Retry-After %q = %v, want %v..%v", tc.value, got, tc.min, tc.max)
+		}
+	}
+}
+
+func TestRejectedErrorUnwrap(t *testing.T) {
+	for _, tc := range []struct {
+		err  *rejectedError
+		want error
+	}{
+		{&rejectedError{Code: 401, StatusLine: "HTTP/1.1 401 Unauthorized"}, ErrAuth},
+		{&rejectedError{Code: 403, StatusLine: "HTTP/1.1 403 Forbidden"}, ErrAuth},
+		{&rejectedError{StatusLine: "ERROR - Bad Password"}, ErrAuth},
+		{&rejectedError{Code: 404, StatusLine: "HTTP/1.1 404 Not Found"}, ErrMountNotFound},
+		{&rejectedError{Code: 200, StatusLine: "SOURCETABLE 200 OK", Sourcetable: true}, ErrSourcetableReturned},
+		{&rejectedError{Code: 503, StatusLine: "HTTP/1.1 503 Service Unavailable"}, ErrUnavailable},
+		{&rejectedError{Code: 429, StatusLine: "HTTP/1.1 429 Too Many Requests"}, ErrUnavailable},
+		{&rejectedError{Code: 400, StatusLine: "HTTP/1.1 400 Bad Request"}, ErrRejected},
+		{&rejectedError{StatusLine: "ERROR - Mount Point Taken"}, ErrRejected},
+	} {
+		if !errors.Is(tc.err, tc.want) {
+			t.Errorf("%q: errors.Is(%v) = false (unwraps to %v)", tc.err.StatusLine, tc.want, tc.err.Unwrap())
+		}
+	}
+}
+
+// serveBody - Caster giả trên net.Pipe: gửi header rồi để write ghi body, trả về response đã đọc
+// cùng reader body phía relay
+func serveBody(t *testing.T, header string, write func(conn net.Conn)) (*ntripResponse, *bufio.Reader) {
+	t.Helper()
+	client, server := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+	go func() {
+		defer server.Close()
+		server.Write([]byte(header))
+		write(server)
+	}()
+	reader := bufio.NewReaderSize(client, BufferSize)
+	resp, err := readResponse(reader, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, sourceBodyReader(reader, resp)
+}
+
+// TestChunkedRoundTrip - Frame ghi qua chunkedWriter (kèm chunk kết thúc) phải đọc lại nguyên vẹn
+// qua sourceBodyReader
+func TestChunkedRoundTrip(t *testing.T) {
+	frames := [][]byte{mustHex(t, rtcm1005Hex), testFrame(1077), rtcmTestFrame(make([]byte, RTCMMaxPayloadLen))}
+	resp, body := serveBody(t, "HTTP/1.1 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n", func(conn net.Conn) {
+		w, closeBody := destBodyWriter(conn, true)
+		for _, f := range frames {
+			w.Write(f)
+			w.Write(nil) // Không được sinh ra chunk rỗng (= chunk kết thúc)
+		}
+		closeBody()
+	})
+	if !resp.chunked() {
+		t.Fatal("response not detected as chunked")
+	}
+	got, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("read chunked body: %v", err)
+	}
+	if want := bytes.Join(frames, nil); !bytes.Equal(got, want) {
+		t.Errorf("round trip: got %d bytes, want %d", len(got), len(want))
+	}
+}
+
+// TestBodyPassThrough - ICY và HTTP/1.0 không có lớp chunked: byte body giữ nguyên
+// (kể cả khi HTTP/1.0 khai Transfer-Encoding)
+func TestBodyPassThrough(t *testing.T) {
+	raw := append([]byte("5\r\n"), bytes.Join([][]byte{testFrame(1077), mustHex(t, rtcm1005Hex)}, nil)...)
+	for _, header := range []string{
+		"ICY 200 OK\r\n",
+		"HTTP/1.0 200 OK\r\nContent-Type: gnss/data\r\n\r\n",
+		"HTTP/1.0 200 OK\r\nTransfer-Encoding: chunked\r\n\r\n",
+	} {
+		resp, body := serveBody(t, header, func(conn net.Conn) { conn.Write(raw) })
+		if resp.chunked() {
+			t.Errorf("%q: treated as chunked", resp.StatusLine)
+		}
+		got, err := io.ReadAll(body)
+		if err != nil || !bytes.Equal(got, raw) {
+			t.Errorf("%q: body = %q, %v; want %q", resp.StatusLine, got, err, raw)
+		}
+	}
+
+	// Phía Dest không chunked: ghi thẳng lên conn
+	client, server := net.Pipe()
+	defer client.Close()
+	w, closeBody := destBodyWriter(server, false)
+	go func() {
+		w.Write(raw)
+		closeBody()
+		server.Close()
+	}()
+	if got, _ := io.ReadAll(client); !bytes.Equal(got, raw) {
+		t.Errorf("non-chunked dest body = %q, want %q", got, raw)
+	}
+}
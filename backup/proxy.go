@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// ================= HTTP CONNECT PROXY =================
+// Proxy dạng http:// hoặc https:// (proxy công ty) mở tunnel tới caster bằng CONNECT.
+// Lỗi phía proxy (không kết nối được proxy, 407...) gắn ErrProxy/ErrProxyAuth,
+// lỗi phía đích (proxy trả 502/503/504 vì caster từ chối) gắn ErrRefused để phân biệt.
+
+// dialHTTPProxy - Kết nối tới proxy rồi gửi CONNECT addr, trả về tunnel tới đích
+func dialHTTPProxy(ctx context.Context, scheme, proxyAddr string, auth *proxy.Auth, addr string) (net.Conn, error) {
+	d := net.Dialer{
+		Timeout:   ProxyDialTimeout,
+		KeepAlive: TCPKeepAlive,
+	}
+	conn, err := d.DialContext(ctx, "tcp", proxyAddr)
+	if err != nil {
+		return nil, wrapError(ErrProxy, fmt.Errorf("proxy refused: dial proxy %s: %w", proxyAddr, err))
+	}
+
+	// Proxy https://: bắt tay TLS với chính proxy trước khi gửi CONNECT
+	if scheme == "https" {
+		host, _, _ := net.SplitHostPort(proxyAddr)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, wrapError(ErrProxy, fmt.Errorf("proxy tls handshake %s: %w", proxyAddr, err))
+		}
+		conn = tlsConn
+	}
+
+	// CONNECT không được treo quá thời gian của ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	req := fmt.Sprintf("CONNECT %s HTTP/1.1\r\nHost: %s\r\n", addr, addr)
+	if auth != nil {
+		cred := base64.StdEncoding.EncodeToString([]byte(auth.User + ":" + auth.Password))
+		req += "Proxy-Authorization: Basic " + cred + "\r\n"
+	}
+	req += "\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		conn.Close()
+		return nil, wrapError(ErrProxy, fmt.Errorf("proxy connect %s: %w", proxyAddr, err))
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		conn.Close()
+		return nil, wrapError(ErrProxy, fmt.Errorf("proxy connect %s: %w", proxyAddr, err))
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		conn.Close()
+		switch resp.StatusCode {
+		case http.StatusProxyAuthRequired:
+			return nil, wrapError(ErrProxyAuth, fmt.Errorf("proxy %s: %s", proxyAddr, resp.Status))
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return nil, wrapError(ErrRefused, fmt.Errorf("target refused via proxy %s: CONNECT %s: %s", proxyAddr, addr, resp.Status))
+		}
+		return nil, wrapError(ErrProxy, fmt.Errorf("proxy refused CONNECT %s: %s", addr, resp.Status))
+	}
+
+	conn.SetDeadline(time.Time{})
+	if reader.Buffered() > 0 {
+		return &bufferedConn{Conn: conn, r: reader}, nil
+	}
+	return conn, nil
+}
+
+// bufferedConn - Giữ lại byte đã đọc dư khi parse phản hồi CONNECT
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) { return c.r.Read(p) }
+
+// proxyForward - Dialer tới SOCKS5 proxy: gắn ErrProxy cho lỗi kết nối tới chính proxy
+type proxyForward struct {
+	d *net.Dialer
+}
+
+func (f proxyForward) Dial(network, addr string) (net.Conn, error) {
+	conn, err := f.d.Dial(network, addr)
+	if err != nil {
+		return nil, wrapError(ErrProxy, fmt.Errorf("proxy refused: dial proxy %s: %w", addr, err))
+	}
+	return conn, nil
+}
+
+// Lỗi bắt tay SOCKS5 của golang.org/x/net chỉ là errors.New (không có kiểu riêng) nên phải dò chuỗi.
+// Chỉ reply "network/host unreachable" và "connection refused" là do đích, còn lại là lỗi proxy.
+var (
+	socksAuthErrors = []string{
+		"username/password authentication failed",
+		"no acceptable authentication methods",
+		"unsupported authentication method",
+		"invalid username/password",
+	}
+	socksTargetErrors = []string{
+		"unknown error network unreachable",
+		"unknown error host unreachable",
+		"unknown error connection refused",
+	}
+)
+
+// classifySOCKSError - Phân loại lỗi dial qua SOCKS5: ErrProxyAuth (sai tài khoản / không thống nhất
+// được phương thức xác thực), ErrRefused (proxy báo đích từ chối / không tới được), còn lại ErrProxy
+func classifySOCKSError(proxyAddr string, err error) error {
+	if errors.Is(err, ErrProxy) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return wrapError(ErrProxy, fmt.Errorf("dial via proxy: %w", err))
+	}
+	msg := err.Error()
+	for _, s := range socksTargetErrors {
+		if strings.Contains(msg, s) {
+			return wrapError(ErrRefused, fmt.Errorf("target refused via proxy %s: %w", proxyAddr, err))
+		}
+	}
+	for _, s := range socksAuthErrors {
+		if strings.Contains(msg, s) {
+			return wrapError(ErrProxyAuth, fmt.Errorf("proxy %s: %w", proxyAddr, err))
+		}
+	}
+	return wrapError(ErrProxy, fmt.Errorf("proxy handshake %s: %w", proxyAddr, err))
+}
+
+// Cổng mặc định khi URL proxy không ghi cổng (như net/http)
+var proxyDefaultPorts = map[string]string{"http": "80", "https": "443", "socks5": "1080"}
+
+// proxyScheme - "socks5", "http" hoặc "https" (mặc định socks5 cho định dạng host:port[:user:pass])
+func proxyScheme(proxyURL string) string {
+	for _, scheme := range []string{"http", "https", "socks5"} {
+		if strings.HasPrefix(proxyURL, scheme+"://") {
+			return scheme
+		}
+	}
+	return "socks5"
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ================= RELOAD PLAN =================
+// reloadConfig chỉ giữ manager.mu trong lúc tính plan và đổi map workers.
+// Dừng worker cũ (có thể đang kẹt trong dial/read) chạy song song ngoài khoá, có timeout,
+// nên /status và các lần reload khác không bị treo theo.
+const (
+	WorkerStopTimeout = 15 * time.Second // Chờ tối đa 1 worker dừng khi reload
+	MaxReloadHistory  = 10               // Số lần reload giữ lại cho API
+)
+
+type ReloadPlan struct {
+	Add     []string `json:"add,omitempty"`     // Station mới (hoặc vừa bật)
+	Remove  []string `json:"remove,omitempty"`  // Station bị xoá / tắt / src_ref lỗi
+	Restart []string `json:"restart,omitempty"` // Config thay đổi
+	Reorder []string `json:"reorder,omitempty"` // Chỉ đổi thứ tự hiển thị
+	Live    []string `json:"live,omitempty"`    // Chỉ đổi trường live (lat/lon, ngưỡng...), áp dụng không restart
+	Invalid []string `json:"invalid,omitempty"` // Bật nhưng không khởi động được (src_ref lỗi)
+}
+
+func (p ReloadPlan) empty() bool {
+	return len(p.Add)+len(p.Remove)+len(p.Restart)+len(p.Reorder)+len(p.Live)+len(p.Invalid) == 0
+}
+
+type ReloadResult struct {
+	Time     time.Time  `json:"time"`
+	Plan     ReloadPlan `json:"plan"`
+	Stopped  []string   `json:"stopped,omitempty"`   // Worker cũ đã dừng hẳn
+	TimedOut []string   `json:"timed_out,omitempty"` // Worker cũ chưa dừng sau WorkerStopTimeout (bỏ lại, tự thoát sau)
+	Started  []string   `json:"started,omitempty"`
+	Deferred []string   `json:"deferred,omitempty"` // Worker mới chờ worker cũ (timed_out) thoát rồi mới chạy
+	Duration string     `json:"duration"`
+	Error    string     `json:"error,omitempty"` // Đọc/parse config lỗi
+}
+
+var (
+	reloadMu      sync.Mutex // Mỗi lúc chỉ 1 lần reload (ticker, API)
+	reloadHistMu  sync.Mutex
+	reloadHistory []ReloadResult
+)
+
+func recordReload(res ReloadResult) {
+	reloadHistMu.Lock()
+	defer reloadHistMu.Unlock()
+	reloadHistory = append(reloadHistory, res)
+	if len(reloadHistory) > MaxReloadHistory {
+		reloadHistory = reloadHistory[len(reloadHistory)-MaxReloadHistory:]
+	}
+}
+
+// stopWorkers - Dừng song song các worker đã cancel, trả về ID đã dừng và ID quá timeout
+func stopWorkers(workers []*Worker, timeout time.Duration) (stopped, timedOut []string) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w *Worker) {
+			defer wg.Done()
+			ok := w.waitStopped(timeout)
+			mu.Lock()
+			defer mu.Unlock()
+			if ok {
+				stopped = append(stopped, w.cfg.ID)
+			} else {
+				timedOut = append(timedOut, w.cfg.ID)
+			}
+		}(w)
+	}
+	wg.Wait()
+	return stopped, timedOut
+}
+
+// waitStopped - Chờ worker (đã cancel) thoát hẳn, false nếu quá timeout
+func (w *Worker) waitStopped(timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return w.waitStoppedCtx(ctx)
+}
+
+// waitStoppedCtx - Chờ worker (đã cancel) thoát hẳn, false nếu ctx kết thúc trước
+func (w *Worker) waitStoppedCtx(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// handleReload - GET: lịch sử reload (plan + kết quả), POST: reload ngay không chờ ticker
+func handleReload(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case http.MethodGet:
+		reloadHistMu.Lock()
+		history := append([]ReloadResult{}, reloadHistory...)
+		reloadHistMu.Unlock()
+		json.NewEncoder(w).Encode(history)
+	case http.MethodPost:
+		res, _ := reloadConfig(true)
+		json.NewEncoder(w).Encode(res)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
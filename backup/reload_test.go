@@ -0,0 +1,57 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+	"time"
+)
+
+// stuckWorker - Worker "cũ" chưa thoát cho tới khi gọi release (giả lập quá WorkerStopTimeout)
+func stuckWorker(id string) (w *Worker, release func()) {
+	w = newWorker(testStation(id, 1), "", 0)
+	w.cancel()
+	w.wg.Add(1)
+	return w, func() { w.wg.Done() }
+}
+
+func TestLaunchAfterWaitsForOldWorker(t *testing.T) {
+	useTempEventLog(t)
+	c := newFakeCaster(t)
+	old, release := stuckWorker("R1")
+
+	w := newWorker(testStation("R1", c.port()), "", 0)
+	w.launchAfter(old)
+	defer func() {
+		w.cancel()
+		if !w.waitStopped(5 * time.Second) {
+			t.Error("replacement did not stop")
+		}
+	}()
+
+	time.Sleep(300 * time.Millisecond)
+	if !w.statusSnapshot().StartTime.IsZero() {
+		t.Fatal("replacement started while the old worker is still running")
+	}
+
+	release()
+	waitFor(t, "replacement running", func() bool { return w.state() == StateRunning })
+}
+
+func TestLaunchAfterCancelledBeforeOldStops(t *testing.T) {
+	useTempEventLog(t)
+	base := runtime.NumGoroutine()
+	old, release := stuckWorker("R2")
+
+	w := newWorker(testStation("R2", 1), "", 0)
+	w.launchAfter(old)
+	w.cancel()
+	if !w.waitStopped(5 * time.Second) {
+		t.Fatal("cancelled replacement still waits for the old worker")
+	}
+	if !w.statusSnapshot().StartTime.IsZero() {
+		t.Fatal("cancelled replacement was started")
+	}
+
+	release()
+	waitGoroutines(t, base)
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ================= SLA REPORT =================
+// Báo cáo khả dụng cho 1 khoảng thời gian bất kỳ, theo station hoặc theo caster đích (dst_host:dst_port).
+// Mọi số liệu lấy từ mẫu lịch sử (history): thời gian Running, số giây có epoch, số sự cố (số lần rời Running)
+// và nguyên nhân (sự kiện lỗi theo loại) đếm lúc lấy mẫu nên không bị mất khi mẫu cũ được gộp.
+// Sự cố dài nhất = chuỗi mẫu liên tiếp có thời gian không Running. Khoảng không có mẫu (relay tắt,
+// station chưa tạo) tính riêng là no_data và cắt ngang sự cố.
+const (
+	ReportGroupStation = "station"
+	ReportGroupDest    = "dest"
+	DefaultEpochRateHz = 1 // Tần suất epoch mong đợi (completeness = epoch_s / (period * rate))
+)
+
+// errorEventTypes - Loại sự kiện được tính là nguyên nhân sự cố (thứ tự = chỉ số trong stationCounters.Causes)
+var errorEventTypes = [...]EventType{EventAuthFailed, EventStale, EventSourceClosed, EventDestClosed, EventError}
+
+// causeIndex - Vị trí của loại sự kiện trong errorEventTypes, -1 nếu không phải sự kiện lỗi
+func causeIndex(t EventType) int {
+	return slices.Index(errorEventTypes[:], t)
+}
+
+type AvailabilityRow struct {
+	Group           string         `json:"group"`              // Station ID hoặc host:port của caster đích
+	Stations        []string       `json:"stations,omitempty"` // Các station gửi tới caster (group=dest)
+	PeriodSeconds   int64          `json:"period_s"`
+	UpSeconds       int64          `json:"up_s"`
+	DownSeconds     int64          `json:"down_s"`
+	NoDataSeconds   int64          `json:"no_data_s"`
+	UptimePct       float64        `json:"uptime_pct"`                 // up / period
+	AvailabilityPct *float64       `json:"availability_pct,omitempty"` // up / (up + down): không tính lúc relay tắt
+	Failures        int            `json:"failures"`
+	MTBF            *float64       `json:"mtbf_s,omitempty"` // Thời gian Running trung bình giữa 2 sự cố
+	MTTR            *float64       `json:"mttr_s,omitempty"` // Thời gian trung bình 1 sự cố
+	LongestOutage   int64          `json:"longest_outage_s"`
+	LongestOutageAt *time.Time     `json:"longest_outage_at,omitempty"`
+	EpochSeconds    int64          `json:"epoch_s"`
+	ExpectedEpochs  int64          `json:"expected_epochs"`
+	CompletenessPct float64        `json:"completeness_pct"`
+	Bytes           int64          `json:"bytes"`
+	Causes          map[string]int `json:"causes,omitempty"` // Số sự kiện lỗi theo loại trong kỳ
+}
+
+type AvailabilityReport struct {
+	From    time.Time         `json:"from"`
+	To      time.Time         `json:"to"`
+	GroupBy string            `json:"group_by"`
+	RateHz  float64           `json:"rate_hz"`
+	Rows    []AvailabilityRow `json:"rows"`
+}
+
+// slaStats - Số liệu cộng dồn được (gộp nhiều station cho group=dest)
+type slaStats struct {
+	period, up, down, noData int64
+	failures                 int
+	longest                  int64
+	longestAt                int64
+	epochs, expected, bytes  int64
+	causes                   map[string]int
+}
+
+func (a *slaStats) merge(b slaStats) {
+	a.period += b.period
+	a.up += b.up
+	a.down += b.down
+	a.noData += b.noData
+	a.failures += b.failures
+	if b.longest > a.longest {
+		a.longest, a.longestAt = b.longest, b.longestAt
+	}
+	a.epochs += b.epochs
+	a.expected += b.expected
+	a.bytes += b.bytes
+	for k, v := range b.causes {
+		a.causes[k] += v
+	}
+}
+
+// stationSLA - Tính số liệu của 1 station trong [from, to)
+func stationSLA(id string, from, to time.Time, rate float64) (slaStats, error) {
+	st := slaStats{period: to.Unix() - from.Unix(), causes: make(map[string]int)}
+	st.expected = int64(float64(st.period) * rate)
+
+	samples, err := history.samples(id, from, to)
+	if err != nil {
+		return st, err
+	}
+
+	// Sự cố dài nhất: trong 1 mẫu chỉ biết tổng thời gian Running, không biết thứ tự: mẫu mở sự cố coi phần
+	// down nằm cuối mẫu, mẫu đang trong sự cố mà có Running thì coi là đã phục hồi.
+	open := false
+	var cur, curStart, sampled int64
+	closeOutage := func() {
+		if open && cur > st.longest {
+			st.longest, st.longestAt = cur, curStart
+		}
+		open, cur = false, 0
+	}
+	prevEnd := int64(-1)
+	for _, s := range samples {
+		if prevEnd >= 0 && s.T > prevEnd+1 {
+			closeOutage() // Khoảng trống không có mẫu
+		}
+		prevEnd = s.T + s.Span
+		sampled += s.Span
+		st.up += s.Up
+		st.epochs += s.Epochs
+		st.bytes += s.Bytes
+		st.failures += int(s.Outages)
+		for k, v := range s.Causes {
+			st.causes[k] += int(v)
+		}
+
+		down := s.Span - s.Up
+		if down <= 0 {
+			closeOutage()
+			continue
+		}
+		st.down += down
+		if open {
+			cur += down
+			if s.Up > 0 {
+				closeOutage()
+			}
+			continue
+		}
+		open = true
+		cur, curStart = down, s.T+s.Up
+	}
+	closeOutage()
+	st.noData = max(st.period-sampled, 0)
+	return st, nil
+}
+
+func roundPct(x float64) float64 {
+	return math.Round(x*1000) / 1000
+}
+
+func (st slaStats) row(group string) AvailabilityRow {
+	r := AvailabilityRow{
+		Group:          group,
+		PeriodSeconds:  st.period,
+		UpSeconds:      st.up,
+		DownSeconds:    st.down,
+		NoDataSeconds:  st.noData,
+		Failures:       st.failures,
+		LongestOutage:  st.longest,
+		EpochSeconds:   st.epochs,
+		ExpectedEpochs: st.expected,
+		Bytes:          st.bytes,
+	}
+	if len(st.causes) > 0 {
+		r.Causes = st.causes
+	}
+	if st.period > 0 {
+		r.UptimePct = roundPct(100 * float64(st.up) / float64(st.period))
+	}
+	if st.up+st.down > 0 {
+		a := roundPct(100 * float64(st.up) / float64(st.up+st.down))
+		r.AvailabilityPct = &a
+	}
+	if st.failures > 0 {
+		mtbf := float64(st.up) / float64(st.failures)
+		mttr := float64(st.down) / float64(st.failures)
+		r.MTBF, r.MTTR = &mtbf, &mttr
+	}
+	if st.longest > 0 {
+		t := time.Unix(st.longestAt, 0)
+		r.LongestOutageAt = &t
+	}
+	if st.expected > 0 {
+		r.CompletenessPct = roundPct(min(100*float64(st.epochs)/float64(st.expected), 100))
+	}
+	return r
+}
+
+// availabilityReport - Báo cáo cho mọi station trong config.json, gộp theo groupBy
+func availabilityReport(configs []ConfigStation, from, to time.Time, groupBy string, rate float64) (AvailabilityReport, error) {
+	rep := AvailabilityReport{From: from, To: to, GroupBy: groupBy, RateHz: rate, Rows: []AvailabilityRow{}}
+
+	groups := make(map[string]*slaStats)
+	members := make(map[string][]string)
+	var order []string
+	for _, cfg := range configs {
+		st, err := stationSLA(cfg.ID, from, to, rate)
+		if err != nil {
+			return rep, fmt.Errorf("%s: %w", cfg.ID, err)
+		}
+		if groupBy == ReportGroupStation {
+			rep.Rows = append(rep.Rows, st.row(cfg.ID))
+			continue
+		}
+		key := "(no dest)"
+		if cfg.DstHost != "" {
+			key = fmt.Sprintf("%s:%d", cfg.DstHost, cfg.DstPort)
+		}
+		if g, ok := groups[key]; ok {
+			g.merge(st)
+		} else {
+			groups[key] = &st
+			order = append(order, key)
+		}
+		members[key] = append(members[key], cfg.ID)
+	}
+	sort.Strings(order)
+	for _, key := range order {
+		r := groups[key].row(key)
+		r.Stations = members[key]
+		rep.Rows = append(rep.Rows, r)
+	}
+	return rep, nil
+}
+
+// writeCSV - 1 dòng mỗi group, causes dạng "auth_failed=2;stale=1"
+func (rep AvailabilityReport) writeCSV(w *csv.Writer) error {
+	optional := func(v *float64) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatFloat(*v, 'f', -1, 64)
+	}
+	w.Write([]string{"group", "stations", "period_s", "up_s", "down_s", "no_data_s", "uptime_pct", "availability_pct",
+		"failures", "mtbf_s", "mttr_s", "longest_outage_s", "longest_outage_at", "epoch_s", "expected_epochs",
+		"completeness_pct", "bytes", "causes"})
+	for _, r := range rep.Rows {
+		var mtbf, mttr *float64
+		if r.MTBF != nil {
+			a, b := math.Round(*r.MTBF), math.Round(*r.MTTR)
+			mtbf, mttr = &a, &b
+		}
+		longestAt := ""
+		if r.LongestOutageAt != nil {
+			longestAt = r.LongestOutageAt.Format(time.RFC3339)
+		}
+		causes := make([]string, 0, len(r.Causes))
+		for k, v := range r.Causes {
+			causes = append(causes, fmt.Sprintf("%s=%d", k, v))
+		}
+		sort.Strings(causes)
+		w.Write([]string{
+			r.Group, strings.Join(r.Stations, ";"),
+			strconv.FormatInt(r.PeriodSeconds, 10), strconv.FormatInt(r.UpSeconds, 10),
+			strconv.FormatInt(r.DownSeconds, 10), strconv.FormatInt(r.NoDataSeconds, 10),
+			strconv.FormatFloat(r.UptimePct, 'f', -1, 64), optional(r.AvailabilityPct),
+			strconv.Itoa(r.Failures), optional(mtbf), optional(mttr),
+			strconv.FormatInt(r.LongestOutage, 10), longestAt,
+			strconv.FormatInt(r.EpochSeconds, 10), strconv.FormatInt(r.ExpectedEpochs, 10),
+			strconv.FormatFloat(r.CompletenessPct, 'f', -1, 64), strconv.FormatInt(r.Bytes, 10),
+			strings.Join(causes, ";"),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// handleAvailabilityReport - GET /api/reports/availability?month=2026-09 hoặc ?from=&to=
+// group: station (mặc định) | dest, format: json (mặc định) | csv, rate: số epoch/giây mong đợi (mặc định 1)
+func handleAvailabilityReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if history == nil {
+		http.Error(w, "History is disabled", http.StatusNotFound)
+		return
+	}
+
+	q := r.URL.Query()
+	now := time.Now()
+	// Mặc định: từ đầu tháng hiện tại (giờ địa phương) tới hiện tại
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	to := now
+	if v := q.Get("month"); v != "" {
+		m, err := time.ParseInLocation("2006-01", v, time.Local)
+		if err != nil {
+			http.Error(w, "Invalid month (YYYY-MM)", http.StatusBadRequest)
+			return
+		}
+		from, to = m, m.AddDate(0, 1, 0)
+	}
+	var err error
+	if from, err = parseHistoryTime(q.Get("from"), from); err != nil {
+		http.Error(w, "Invalid from: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if to, err = parseHistoryTime(q.Get("to"), to); err != nil {
+		http.Error(w, "Invalid to: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	to = minTime(to, now)
+	if !from.Before(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+
+	groupBy := q.Get("group")
+	if groupBy == "" {
+		groupBy = ReportGroupStation
+	}
+	if groupBy != ReportGroupStation && groupBy != ReportGroupDest {
+		http.Error(w, "Invalid group (station|dest)", http.StatusBadRequest)
+		return
+	}
+	rate := float64(DefaultEpochRateHz)
+	if v := q.Get("rate"); v != "" {
+		if rate, err = strconv.ParseFloat(v, 64); err != nil || rate <= 0 {
+			http.Error(w, "Invalid rate", http.StatusBadRequest)
+			return
+		}
+	}
+
+	file, err := os.ReadFile(ConfigFile)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var configs []ConfigStation
+	if err := json.Unmarshal(file, &configs); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rep, err := availabilityReport(configs, from, to, groupBy, rate)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	name := fmt.Sprintf("availability_%s_%s_%s", groupBy, from.Format("20060102"), to.Format("20060102"))
+	switch q.Get("format") {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		if q.Get("download") != "" {
+			w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.json"`)
+		}
+		json.NewEncoder(w).Encode(rep)
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.csv"`)
+		rep.writeCSV(csv.NewWriter(w))
+	default:
+		http.Error(w, "Invalid format (json|csv)", http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// useTempHistory - Lịch sử ghi vào thư mục tạm
+func useTempHistory(t testing.TB) *historyStore {
+	old := history
+	history = newHistoryStore(HistorySettings{Dir: t.TempDir()})
+	t.Cleanup(func() { history = old })
+	return history
+}
+
+// TestSLAFailuresSurviveDownsample - 15 mẫu 60s, mỗi mẫu 1 lần rớt: sau khi gộp thành 1 mẫu 900s
+// vẫn phải ra 15 sự cố (đoán theo chuỗi mẫu thì chỉ còn 1)
+func TestSLAFailuresSurviveDownsample(t *testing.T) {
+	useTempEventLog(t)
+	h := useTempHistory(t)
+	from := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(15 * time.Minute)
+	for i := 0; i < 15; i++ {
+		s := historySample{T: from.Unix() + int64(i*60), Span: 60, Up: 50, Outages: 1,
+			Causes: map[string]int64{string(EventStale): 1}}
+		if err := h.append("SLA", s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(stage string) {
+		st, err := stationSLA("SLA", from, to, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if st.failures != 15 || st.causes[string(EventStale)] != 15 {
+			t.Errorf("%s: failures=%d causes=%v, want 15/stale=15", stage, st.failures, st.causes)
+		}
+	}
+	check("raw")
+	if err := compactDay(filepath.Join(h.stationDir("SLA"), dayFile(from)), 900); err != nil {
+		t.Fatal(err)
+	}
+	if samples, _ := h.samples("SLA", from, to); len(samples) != 1 {
+		t.Fatalf("got %d samples after downsample, want 1", len(samples))
+	}
+	check("downsampled")
+}
+
+func TestTransitionCountsOutages(t *testing.T) {
+	s := &StationStatus{}
+	for _, state := range []StationState{StateStarting, StateRunning, StateWaiting, StateRunning, StateStopped} {
+		s.transition(state, "")
+	}
+	if s.outages != 1 {
+		t.Errorf("outages = %d, want 1 (stopping the worker is not an outage)", s.outages)
+	}
+}
+
+func TestHistorySampleCountsOutages(t *testing.T) {
+	useTempEventLog(t)
+	h := useTempHistory(t)
+	w := newWorker(testStation("HS", 1), "", 0)
+	addTestWorker(t, w)
+
+	w.setState(StateRunning, "")
+	w.setState(StateWaiting, "")
+	w.event(StationEvent{Type: EventStale})
+	w.event(StationEvent{Type: EventConnecting}) // Không phải sự kiện lỗi
+	now := time.Now()
+	h.sample(now)
+	h.sample(now.Add(time.Minute)) // Không có gì mới: mẫu chỉ chứa phần tăng thêm
+
+	samples, err := h.samples("HS", now.Add(-time.Hour), now.Add(time.Hour))
+	if err != nil || len(samples) != 2 {
+		t.Fatalf("samples = %+v, %v", samples, err)
+	}
+	if s := samples[0]; s.Outages != 1 || len(s.Causes) != 1 || s.Causes[string(EventStale)] != 1 {
+		t.Errorf("first sample = %+v, want 1 outage and stale=1", s)
+	}
+	if s := samples[1]; s.Outages != 0 || s.Causes != nil {
+		t.Errorf("second sample = %+v, want no new outages", s)
+	}
+}
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ================= RTCM3 FRAMING =================
+// Cấu trúc 1 frame RTCM3: 0xD3 | 6 bit reserved (=0) + 10 bit length | payload | CRC-24Q (3 byte)
+// CRC được tính trên header (3 byte) + payload (0-1023 byte).
+const (
+	RTCMPreamble      = 0xD3
+	RTCMHeaderLen     = 3
+	RTCMCRCLen        = 3
+	RTCMMaxPayloadLen = 1023
+	RTCMMaxFrameLen   = RTCMHeaderLen + RTCMMaxPayloadLen + RTCMCRCLen
+)
+
+// Bảng CRC-24Q (đa thức 0x1864CFB) tính sẵn một lần
+var crc24qTable = func() [256]uint32 {
+	var table [256]uint32
+	for i := 0; i < 256; i++ {
+		crc := uint32(i) << 16
+		for j := 0; j < 8; j++ {
+			crc <<= 1
+			if crc&0x1000000 != 0 {
+				crc ^= 0x1864CFB
+			}
+		}
+		table[i] = crc & 0xFFFFFF
+	}
+	return table
+}()
+
+func crc24q(data []byte) uint32 {
+	var crc uint32
+	for _, b := range data {
+		crc = ((crc << 8) & 0xFFFFFF) ^ crc24qTable[byte(crc>>16)^b]
+	}
+	return crc
+}
+
+// rtcmFramer - Tách luồng byte từ source thành các frame RTCM3 hoàn chỉnh.
+// Rác giữa các frame và frame sai CRC bị bỏ qua và được đếm vào counters.
+type rtcmFramer struct {
+	r            *bufio.Reader
+	crcErrors    *int64 // Số frame sai CRC (atomic)
+	droppedBytes *int64 // Số byte rác bị bỏ (atomic)
+	frame        []byte // Buffer trả về, chỉ hợp lệ tới lần gọi Next() kế tiếp
+}
+
+func newRTCMFramer(r *bufio.Reader, crcErrors, droppedBytes *int64) *rtcmFramer {
+	return &rtcmFramer{
+		r:            r,
+		crcErrors:    crcErrors,
+		droppedBytes: droppedBytes,
+		frame:        make([]byte, RTCMMaxFrameLen),
+	}
+}
+
+// Next - Đọc tới khi có 1 frame hợp lệ. Slice trả về dùng chung buffer nội bộ.
+func (f *rtcmFramer) Next() ([]byte, error) {
+	return f.next(true)
+}
+
+// nextBuffered - Như Next nhưng chỉ dùng dữ liệu đã nằm sẵn trong buffer (không block).
+// Trả về nil nếu chưa có frame hoàn chỉnh. Dùng để gom nhiều frame thành 1 lần ghi sang Dest.
+func (f *rtcmFramer) nextBuffered() []byte {
+	frame, _ := f.next(false)
+	return frame
+}
+
+func (f *rtcmFramer) next(block bool) ([]byte, error) {
+	for {
+		// 1. Tìm preamble 0xD3, bỏ qua mọi byte rác phía trước
+		if !block && f.r.Buffered() < 1 {
+			return nil, nil
+		}
+		b, err := f.r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if b != RTCMPreamble {
+			atomic.AddInt64(f.droppedBytes, 1)
+			continue
+		}
+		if err := f.r.UnreadByte(); err != nil {
+			return nil, err
+		}
+
+		// 2. Đọc header: 6 bit reserved phải bằng 0, nếu không thì 0xD3 này là rác
+		if !block && f.r.Buffered() < RTCMHeaderLen {
+			return nil, nil
+		}
+		header, err := f.r.Peek(RTCMHeaderLen)
+		if err != nil {
+			if f.truncated(header, err) {
+				continue
+			}
+			return nil, err
+		}
+		if header[1]&0xFC != 0 {
+			f.r.Discard(1)
+			atomic.AddInt64(f.droppedBytes, 1)
+			continue
+		}
+		payloadLen := int(header[1]&0x03)<<8 | int(header[2])
+		frameLen := RTCMHeaderLen + payloadLen + RTCMCRCLen
+
+		// 3. Đợi đủ cả frame rồi kiểm tra CRC-24Q
+		if !block && f.r.Buffered() < frameLen {
+			return nil, nil
+		}
+		data, err := f.r.Peek(frameLen)
+		if err != nil {
+			if f.truncated(data, err) {
+				continue
+			}
+			return nil, err
+		}
+		body := frameLen - RTCMCRCLen
+		want := uint32(data[body])<<16 | uint32(data[body+1])<<8 | uint32(data[body+2])
+		if crc24q(data[:body]) != want {
+			// Sai CRC -> bỏ preamble và dò lại từ byte kế tiếp
+			f.r.Discard(1)
+			atomic.AddInt64(f.crcErrors, 1)
+			atomic.AddInt64(f.droppedBytes, 1)
+			continue
+		}
+
+		n := copy(f.frame, data)
+		f.r.Discard(frameLen)
+		return f.frame[:n], nil
+	}
+}
+
+// truncated - Luồng kết thúc giữa chừng 1 frame: 0xD3 này có thể là giả (length trùm qua EOF),
+// bỏ nó để dò tiếp phần còn lại thay vì mất luôn các frame thật phía sau
+func (f *rtcmFramer) truncated(data []byte, err error) bool {
+	if err != io.EOF || len(data) == 0 {
+		return false
+	}
+	f.r.Discard(1)
+	atomic.AddInt64(f.droppedBytes, 1)
+	return true
+}
+
+// splitRTCMFrames - Duyệt các frame trong 1 lô đã qua framer (từ hub: chỉ gồm frame hoàn chỉnh,
+// đúng CRC nên chỉ cần đọc length). Trả về số frame.
+func splitRTCMFrames(data []byte, fn func(frame []byte)) int {
+	n := 0
+	for len(data) >= RTCMHeaderLen {
+		frameLen := RTCMHeaderLen + (int(data[1]&0x03)<<8 | int(data[2])) + RTCMCRCLen
+		if frameLen > len(data) {
+			break
+		}
+		fn(data[:frameLen])
+		data = data[frameLen:]
+		n++
+	}
+	return n
+}
+
+// rtcmMessageType - 12 bit đầu của payload là số hiệu message (1005, 1077...)
+func rtcmMessageType(frame []byte) int {
+	if len(frame) < RTCMHeaderLen+2 {
+		return 0
+	}
+	return int(frame[3])<<4 | int(frame[4])>>4
+}
+
+// isObservationMessage - Message quan sát (mỗi epoch 1 lần): 1001-1004, 1009-1012 (legacy) và MSM 1071-1137
+func isObservationMessage(msgType int) bool {
+	return msgType >= 1001 && msgType <= 1004 ||
+		msgType >= 1009 && msgType <= 1012 ||
+		msgType >= 1071 && msgType <= 1137
+}
+
+// ================= RTCM MESSAGE-TYPE STATISTICS =================
+// Cửa sổ tính tần suất (Hz) cho từng loại message
+const RTCMRateWindow = 10 * time.Second
+
+// Tên ngắn gọn của các message hay gặp (hiển thị trên dashboard)
+var rtcmMessageNames = map[int]string{
+	1005: "Station ARP",
+	1006: "Station ARP + Height",
+	1007: "Antenna Descriptor",
+	1008: "Antenna Descriptor + Serial",
+	1019: "GPS Ephemeris",
+	1020: "GLONASS Ephemeris",
+	1033: "Receiver & Antenna",
+	1042: "BeiDou Ephemeris",
+	1044: "QZSS Ephemeris",
+	1045: "Galileo F/NAV Ephemeris",
+	1046: "Galileo I/NAV Ephemeris",
+	1074: "GPS MSM4",
+	1075: "GPS MSM5",
+	1077: "GPS MSM7",
+	1084: "GLONASS MSM4",
+	1085: "GLONASS MSM5",
+	1087: "GLONASS MSM7",
+	1094: "Galileo MSM4",
+	1095: "Galileo MSM5",
+	1097: "Galileo MSM7",
+	1114: "QZSS MSM4",
+	1117: "QZSS MSM7",
+	1124: "BeiDou MSM4",
+	1125: "BeiDou MSM5",
+	1127: "BeiDou MSM7",
+	1230: "GLONASS Code-Phase Biases",
+}
+
+type MessageTypeStat struct {
+	Type     int       `json:"type"`
+	Name     string    `json:"name,omitempty"`
+	Count    int64     `json:"count"`
+	RateHz   float64   `json:"rate_hz"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+type msgTypeCounter struct {
+	count    int64
+	lastSeen time.Time
+	rate     float64   // Tần suất của cửa sổ gần nhất
+	winStart time.Time // Bắt đầu cửa sổ hiện tại
+	winCount int64     // count tại winStart
+}
+
+// rtcmMsgStats - Thống kê theo message type của 1 station (sống cùng Worker, qua nhiều session)
+type rtcmMsgStats struct {
+	mu    sync.Mutex
+	types map[int]*msgTypeCounter
+}
+
+func newRTCMMsgStats() *rtcmMsgStats {
+	return &rtcmMsgStats{types: make(map[int]*msgTypeCounter)}
+}
+
+func (s *rtcmMsgStats) observe(msgType int, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c, ok := s.types[msgType]
+	if !ok {
+		c = &msgTypeCounter{winStart: now}
+		s.types[msgType] = c
+	}
+	c.count++
+	c.lastSeen = now
+
+	// Hết cửa sổ -> chốt tần suất và mở cửa sổ mới
+	if elapsed := now.Sub(c.winStart); elapsed >= RTCMRateWindow {
+		c.rate = float64(c.count-c.winCount) / elapsed.Seconds()
+		c.winStart = now
+		c.winCount = c.count
+	}
+}
+
+// snapshot - Danh sách thống kê, sắp xếp theo message type
+func (s *rtcmMsgStats) snapshot(now time.Time) []MessageTypeStat {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := make([]MessageTypeStat, 0, len(s.types))
+	for msgType, c := range s.types {
+		rate := c.rate
+		elapsed := now.Sub(c.winStart)
+		if rate == 0 && elapsed >= time.Second {
+			// Chưa đủ 1 cửa sổ: ước lượng từ dữ liệu đang có
+			rate = float64(c.count-c.winCount) / elapsed.Seconds()
+		} else if now.Sub(c.lastSeen) > RTCMRateWindow {
+			// Message ngừng đến -> tần suất giảm dần về 0 thay vì giữ giá trị cũ
+			rate = float64(c.count-c.winCount) / elapsed.Seconds()
+		}
+		result = append(result, MessageTypeStat{
+			Type:     msgType,
+			Name:     rtcmMessageNames[msgType],
+			Count:    c.count,
+			RateHz:   math.Round(rate*100) / 100,
+			LastSeen: c.lastSeen,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Type < result[j].Type })
+	return result
+}
+
+// ================= RTCM 1005/1006: VỊ TRÍ TRẠM (ARP) =================
+// WGS84
+const (
+	wgs84A  = 6378137.0
+	wgs84F  = 1 / 298.257223563
+	wgs84E2 = wgs84F * (2 - wgs84F)
+
+	// Khoảng cách mặc định giữa toạ độ config và toạ độ RTCM để cảnh báo
+	DefaultPosWarnDistance = 1000.0 // mét
+)
+
+type StationPosition struct {
+	RefStationID  int       `json:"ref_station_id"` // DF003
+	X             float64   `json:"x"`              // ECEF (m)
+	Y             float64   `json:"y"`
+	Z             float64   `json:"z"`
+	Lat           float64   `json:"lat"`
+	Lon           float64   `json:"lon"`
+	Height        float64   `json:"height"`                   // Chiều cao ellipsoid (m) của ARP
+	AntennaHeight float64   `json:"antenna_height,omitempty"` // DF028 (1006): ARP cao hơn mốc bao nhiêu (m)
+	MarkerHeight  float64   `json:"marker_height,omitempty"`  // 1006: chiều cao ellipsoid của mốc = Height - DF028
+	MsgType       int       `json:"msg_type"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// rtcmBits - Đọc n bit (big-endian) bắt đầu từ bit pos trong payload
+func rtcmBits(payload []byte, pos, n int) uint64 {
+	var v uint64
+	for i := pos; i < pos+n; i++ {
+		v = v<<1 | uint64(payload[i/8]>>(7-uint(i%8))&1)
+	}
+	return v
+}
+
+func rtcmSignedBits(payload []byte, pos, n int) int64 {
+	v := rtcmBits(payload, pos, n)
+	if v&(1<<uint(n-1)) != 0 {
+		return int64(v) - int64(1)<<uint(n)
+	}
+	return int64(v)
+}
+
+// decodeStationPosition - Giải mã ECEF ARP từ frame 1005/1006 và đổi sang lat/lon/height
+func decodeStationPosition(frame []byte) (*StationPosition, error) {
+	msgType := rtcmMessageType(frame)
+	if msgType != 1005 && msgType != 1006 {
+		return nil, fmt.Errorf("not a station position message: %d", msgType)
+	}
+	payload := frame[RTCMHeaderLen : len(frame)-RTCMCRCLen]
+	need := 19 // 1005: 152 bit
+	if msgType == 1006 {
+		need = 21 // 1006: 168 bit
+	}
+	if len(payload) < need {
+		return nil, fmt.Errorf("rtcm %d too short: %d bytes", msgType, len(payload))
+	}
+
+	pos := &StationPosition{
+		RefStationID: int(rtcmBits(payload, 12, 12)),
+		X:            float64(rtcmSignedBits(payload, 34, 38)) * 0.0001,
+		Y:            float64(rtcmSignedBits(payload, 74, 38)) * 0.0001,
+		Z:            float64(rtcmSignedBits(payload, 114, 38)) * 0.0001,
+		MsgType:      msgType,
+	}
+	if pos.X == 0 && pos.Y == 0 && pos.Z == 0 {
+		return nil, fmt.Errorf("rtcm %d has empty ARP", msgType)
+	}
+	pos.Lat, pos.Lon, pos.Height = ecefToGeodetic(pos.X, pos.Y, pos.Z)
+	if msgType == 1006 {
+		// ECEF trong 1005/1006 đã là ARP; DF028 là độ cao ARP so với mốc nên mốc nằm thấp hơn
+		pos.AntennaHeight = float64(rtcmBits(payload, 152, 16)) * 0.0001
+		pos.MarkerHeight = pos.Height - pos.AntennaHeight
+	}
+	return pos, nil
+}
+
+// ecefToGeodetic - ECEF (m) -> lat/lon (độ), height (m) trên ellipsoid WGS84
+func ecefToGeodetic(x, y, z float64) (lat, lon, h float64) {
+	p := math.Hypot(x, y)
+	lon = math.Atan2(y, x)
+
+	// Lặp tới khi hội tụ (thường 3-4 vòng)
+	phi := math.Atan2(z, p*(1-wgs84E2))
+	for i := 0; i < 10; i++ {
+		sinPhi := math.Sin(phi)
+		n := wgs84A / math.Sqrt(1-wgs84E2*sinPhi*sinPhi)
+		h = p/math.Cos(phi) - n
+		next := math.Atan2(z, p*(1-wgs84E2*n/(n+h)))
+		if math.Abs(next-phi) < 1e-12 {
+			phi = next
+			break
+		}
+		phi = next
+	}
+	return phi * 180 / math.Pi, lon * 180 / math.Pi, h
+}
+
+// haversineDistance - Khoảng cách mặt đất (m) giữa 2 toạ độ
+func haversineDistance(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371000.0
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+// updateStationPosition - Cập nhật vị trí từ frame 1005/1006, cảnh báo nếu lệch xa toạ độ config
+func (w *Worker) updateStationPosition(frame []byte) {
+	pos, err := decodeStationPosition(frame)
+	if err != nil {
+		return
+	}
+	pos.UpdatedAt = time.Now()
+	prev := w.rtcmPos.Swap(pos)
+
+	live := w.settings()
+	offset := haversineDistance(live.Lat, live.Lon, pos.Lat, pos.Lon)
+	mismatch := offset > w.posWarnDistance()
+	// Chỉ log khi lần đầu nhận vị trí hoặc trạng thái lệch thay đổi (tránh spam mỗi 10s)
+	if prev == nil || mismatch != w.posMismatch {
+		if mismatch {
+			log.Printf("[%s] ⚠️  Config lat/lon (%.6f, %.6f) is %.0fm away from RTCM %d ARP (%.6f, %.6f)",
+				w.cfg.ID, live.Lat, live.Lon, offset, pos.MsgType, pos.Lat, pos.Lon)
+		} else {
+			log.Printf("[%s] RTCM %d ARP: %.6f, %.6f, h=%.2fm (offset %.0fm)",
+				w.cfg.ID, pos.MsgType, pos.Lat, pos.Lon, pos.Height, offset)
+		}
+	}
+	w.posMismatch = mismatch
+}
+
+func (w *Worker) posWarnDistance() float64 {
+	if d := w.settings().PosWarnDistance; d > 0 {
+		return d
+	}
+	return DefaultPosWarnDistance
+}
+
+// ggaPosition - Toạ độ dùng cho GGA gửi lên source: ưu tiên vị trí RTCM nếu được bật
+func (w *Worker) ggaPosition() (lat, lon, alt float64) {
+	live := w.settings()
+	if live.UseRTCMPosition {
+		if pos := w.rtcmPos.Load(); pos != nil {
+			// GGA dùng độ cao MSL = h - N (N = -5.0m như trong câu GGA)
+			return pos.Lat, pos.Lon, pos.Height + 5.0
+		}
+	}
+	return live.Lat, live.Lon, 100.0
+}
+
+// fillPositionStatus - Đưa vị trí RTCM và độ lệch vào bản sao status
+func (w *Worker) fillPositionStatus(s *StationStatus) {
+	pos := w.rtcmPos.Load()
+	if pos == nil {
+		return
+	}
+	s.RTCMPosition = pos
+	live := w.settings()
+	s.PositionOffset = math.Round(haversineDistance(live.Lat, live.Lon, pos.Lat, pos.Lon))
+	if s.PositionOffset > w.posWarnDistance() {
+		s.PositionWarning = fmt.Sprintf("Config lat/lon is %.0fm from RTCM %d position (%.6f, %.6f)",
+			s.PositionOffset, pos.MsgType, pos.Lat, pos.Lon)
+	}
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"testing"
+)
+
+// Frame 1005 mẫu trong chuẩn RTCM 10403: station 2003, ARP (1114104.5999, -4850729.7108, 3975521.4643)
+const rtcm1005Hex = "D300133ED7D30202980EDEEF34B4BD62AC0941986F33360B98"
+
+func mustHex(t testing.TB, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// rtcmTestFrame - Đóng gói payload thành frame RTCM3 có CRC đúng
+func rtcmTestFrame(payload []byte) []byte {
+	frame := append([]byte{RTCMPreamble, byte(len(payload) >> 8), byte(len(payload))}, payload...)
+	crc := crc24q(frame)
+	return append(frame, byte(crc>>16), byte(crc>>8), byte(crc))
+}
+
+func TestCRC24Q(t *testing.T) {
+	f := mustHex(t, rtcm1005Hex)
+	body := len(f) - RTCMCRCLen
+	if got := crc24q(f[:body]); got != 0x360B98 {
+		t.Errorf("crc24q = %06X, want 360B98", got)
+	}
+	// CRC tính trên cả frame (kèm CRC) phải bằng 0
+	if got := crc24q(f); got != 0 {
+		t.Errorf("crc24q over the whole frame = %06X, want 0", got)
+	}
+}
+
+func TestRTCMFramer(t *testing.T) {
+	valid := mustHex(t, rtcm1005Hex)
+	badCRC := append([]byte{}, valid...)
+	badCRC[len(badCRC)-1] ^= 0xFF
+	big := rtcmTestFrame(make([]byte, RTCMMaxPayloadLen))
+	join := func(parts ...[]byte) []byte { return bytes.Join(parts, nil) }
+
+	for _, tc := range []struct {
+		name      string
+		in        []byte
+		frames    [][]byte
+		crcErrors int64
+		dropped   int64
+	}{
+		{"valid 1005", valid, [][]byte{valid}, 0, 0},
+		{"garbage before frame", join([]byte("$GPGGA,\r\n"), valid), [][]byte{valid}, 0, 9},
+		{"crc failure", join(badCRC, valid), [][]byte{valid}, 1, int64(len(badCRC))},
+		// 0xD3 giả với reserved bit khác 0 (length > 1023): bỏ 1 byte rồi dò tiếp
+		{"reserved bits set", join([]byte{RTCMPreamble, 0xFF, 0xFF}, valid), [][]byte{valid}, 0, 3},
+		// 0xD3 giả khai length 1023 trùm lên frame thật phía sau: sai CRC, dò lại và vẫn thấy frame thật
+		{"false preamble over valid frame", join([]byte{RTCMPreamble, 0x03, 0xFF}, valid, big),
+			[][]byte{valid, big}, 1, 3},
+		// 0xD3 giả khai length vượt quá EOF: không được nuốt frame thật phía sau
+		{"false preamble past EOF", join([]byte{RTCMPreamble, 0x03, 0xFF}, valid), [][]byte{valid}, 0, 3},
+		// Frame cụt ở cuối luồng không phải frame: bị đếm là byte rác
+		{"truncated at EOF", join(valid, valid[:len(valid)-4]), [][]byte{valid}, 0, int64(len(valid) - 4)},
+		{"several frames", join(valid, testFrame(1077), big, valid), [][]byte{valid, testFrame(1077), big, valid}, 0, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var crcErrors, dropped int64
+			f := newRTCMFramer(bufio.NewReaderSize(bytes.NewReader(tc.in), 4*RTCMMaxFrameLen), &crcErrors, &dropped)
+			var got [][]byte
+			for {
+				frame, err := f.Next()
+				if err != nil {
+					if !errors.Is(err, io.EOF) {
+						t.Fatalf("Next: %v", err)
+					}
+					break
+				}
+				got = append(got, append([]byte{}, frame...))
+			}
+			if len(got) != len(tc.frames) {
+				t.Fatalf("got %d frames, want %d", len(got), len(tc.frames))
+			}
+			for i := range got {
+				if !bytes.Equal(got[i], tc.frames[i]) {
+					t.Errorf("frame %d = % X, want % X", i, got[i], tc.frames[i])
+				}
+			}
+			if crcErrors != tc.crcErrors || dropped != tc.dropped {
+				t.Errorf("crc errors = %d, dropped = %d, want %d/%d", crcErrors, dropped, tc.crcErrors, tc.dropped)
+			}
+		})
+	}
+}
+
+// TestRTCMFramerNextBuffered - nextBuffered chỉ trả frame đã nằm đủ trong buffer, không block
+// và không nuốt frame còn thiếu
+func TestRTCMFramerNextBuffered(t *testing.T) {
+	valid := mustHex(t, rtcm1005Hex)
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	var crcErrors, dropped int64
+	f := newRTCMFramer(bufio.NewReaderSize(pr, 4*RTCMMaxFrameLen), &crcErrors, &dropped)
+
+	go pw.Write(bytes.Join([][]byte{valid, testFrame(1077), valid[:10]}, nil))
+	if frame, err := f.Next(); err != nil || !bytes.Equal(frame, valid) {
+		t.Fatalf("Next = % X, %v", frame, err)
+	}
+	if frame := f.nextBuffered(); !bytes.Equal(frame, testFrame(1077)) {
+		t.Fatalf("nextBuffered = % X, want the buffered 1077", frame)
+	}
+	if frame := f.nextBuffered(); frame != nil {
+		t.Fatalf("nextBuffered returned % X for a partial frame", frame)
+	}
+
+	// Phần còn lại tới sau: Next phải ghép thành frame nguyên vẹn
+	go pw.Write(valid[10:])
+	if frame, err := f.Next(); err != nil || !bytes.Equal(frame, valid) {
+		t.Fatalf("Next after the rest arrived = % X, %v", frame, err)
+	}
+	if crcErrors != 0 || dropped != 0 {
+		t.Errorf("crc errors = %d, dropped = %d", crcErrors, dropped)
+	}
+}
+
+// geodeticToECEF - Chiều ngược của ecefToGeodetic (công thức đóng) để kiểm tra độc lập
+func geodeticToECEF(lat, lon, h float64) (x, y, z float64) {
+	phi, lam := lat*math.Pi/180, lon*math.Pi/180
+	n := wgs84A / math.Sqrt(1-wgs84E2*math.Sin(phi)*math.Sin(phi))
+	x = (n + h) * math.Cos(phi) * math.Cos(lam)
+	y = (n + h) * math.Cos(phi) * math.Sin(lam)
+	z = (n*(1-wgs84E2) + h) * math.Sin(phi)
+	return
+}
+
+func TestDecodeStationPosition(t *testing.T) {
+	f1005 := mustHex(t, rtcm1005Hex)
+
+	// 1006 = payload 1005 với số hiệu 1006 + DF028 = 1.5m
+	payload := append([]byte{}, f1005[RTCMHeaderLen:len(f1005)-RTCMCRCLen]...)
+	payload[0], payload[1] = 0x3E, 0xE0|payload[1]&0x0F // 1006 = 0x3EE
+	payload = append(payload, 15000>>8, 15000&0xFF)
+	f1006 := rtcmTestFrame(payload)
+
+	const wantLat, wantLon, wantH = 38.80475943, -77.06477360, 114.5611
+	for _, tc := range []struct {
+		name    string
+		frame   []byte
+		msgType int
+		antenna float64
+		wantErr bool
+	}{
+		{"1005", f1005, 1005, 0, false},
+		{"1006", f1006, 1006, 1.5, false},
+		{"1006 without DF028", rtcmTestFrame(payload[:19]), 0, 0, true},
+		{"1005 too short", rtcmTestFrame(f1005[RTCMHeaderLen:15]), 0, 0, true},
+		{"other message", testFrame(1077), 0, 0, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			pos, err := decodeStationPosition(tc.frame)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("got %+v, want error", pos)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pos.MsgType != tc.msgType || pos.RefStationID != 2003 {
+				t.Errorf("msg type %d, station %d", pos.MsgType, pos.RefStationID)
+			}
+			if math.Abs(pos.X-1114104.5999) > 1e-6 || math.Abs(pos.Y+4850729.7108) > 1e-6 || math.Abs(pos.Z-3975521.4643) > 1e-6 {
+				t.Errorf("ECEF = (%.4f, %.4f, %.4f)", pos.X, pos.Y, pos.Z)
+			}
+			if math.Abs(pos.Lat-wantLat) > 1e-8 || math.Abs(pos.Lon-wantLon) > 1e-8 || math.Abs(pos.Height-wantH) > 1e-3 {
+				t.Errorf("lat/lon/h = %.8f, %.8f, %.4f", pos.Lat, pos.Lon, pos.Height)
+			}
+			// Height luôn là của ARP; 1006 thêm độ cao mốc thấp hơn DF028
+			if pos.AntennaHeight != tc.antenna {
+				t.Errorf("antenna height = %v, want %v", pos.AntennaHeight, tc.antenna)
+			}
+			if tc.msgType == 1006 && math.Abs(pos.MarkerHeight-(wantH-1.5)) > 1e-3 {
+				t.Errorf("marker height = %.4f, want %.4f", pos.MarkerHeight, wantH-1.5)
+			}
+		})
+	}
+}
+
+func TestECEFToGeodeticRoundTrip(t *testing.T) {
+	for _, p := range [][3]float64{
+		{21.028511, 105.804817, 25},   // Hà Nội
+		{10.762622, 106.660172, -2.5}, // TP.HCM, dưới ellipsoid
+		{0, 0, 0},
+		{-33.8688, 151.2093, 58},
+		{89.9, -45, 2800},
+		{45, 180, 8848},
+	} {
+		x, y, z := geodeticToECEF(p[0], p[1], p[2])
+		lat, lon, h := ecefToGeodetic(x, y, z)
+		if math.Abs(lat-p[0]) > 1e-9 || math.Abs(math.Mod(lon-p[1]+540, 360)-180) > 1e-9 || math.Abs(h-p[2]) > 1e-3 {
+			t.Errorf("%v -> (%.3f, %.3f, %.3f) -> (%.10f, %.10f, %.5f)", p, x, y, z, lat, lon, h)
+		}
+	}
+}
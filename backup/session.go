@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// ================= SESSION SUPERVISION =================
+// Mỗi session (1 lần kết nối Source/Dest) có context riêng, con của w.ctx.
+// Luồng phụ (NMEA, đọc Dest, ghi Dest, kiểm tra source chính) chạy qua sessionGroup:
+// luồng nào lỗi trước thì huỷ cả session, và session chỉ kết thúc khi mọi luồng phụ đã thoát.
+type sessionGroup struct {
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+	wg     sync.WaitGroup
+}
+
+func newSessionGroup(parent context.Context) *sessionGroup {
+	ctx, cancel := context.WithCancelCause(parent)
+	return &sessionGroup{ctx: ctx, cancel: cancel}
+}
+
+// Go - Chạy luồng phụ, lỗi trả về kết thúc session
+func (g *sessionGroup) Go(fn func(ctx context.Context) error) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if err := fn(g.ctx); err != nil {
+			g.fail(err)
+		}
+	}()
+}
+
+// fail - Kết thúc session với lỗi err (chỉ lỗi đầu tiên được giữ)
+func (g *sessionGroup) fail(err error) {
+	g.cancel(err)
+}
+
+// err - Lý do session bị huỷ (nil nếu còn chạy). Worker dừng -> context.Canceled.
+func (g *sessionGroup) err() error {
+	if g.ctx.Err() == nil {
+		return nil
+	}
+	return context.Cause(g.ctx)
+}
+
+// wait - Huỷ session và chờ mọi luồng phụ thoát
+func (g *sessionGroup) wait() {
+	g.cancel(context.Canceled)
+	g.wg.Wait()
+}
+
+// interrupt - Khi session kết thúc: cắt Read/Write đang chờ trên conn để luồng đang block thoát ngay
+func (g *sessionGroup) interrupt(conn net.Conn) {
+	context.AfterFunc(g.ctx, func() {
+		conn.SetDeadline(time.Now())
+	})
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeCaster - Caster giả trên 127.0.0.1: GET = source (phát frame RTCM đều đặn), POST/SOURCE = dest (đọc bỏ)
+type fakeCaster struct {
+	ln net.Listener
+	wg sync.WaitGroup
+
+	mu    sync.Mutex
+	conns map[string][]net.Conn // "source" | "dest"
+}
+
+func newFakeCaster(t testing.TB) *fakeCaster {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &fakeCaster{ln: ln, conns: make(map[string][]net.Conn)}
+	c.wg.Add(1)
+	go c.serve()
+	t.Cleanup(c.close)
+	return c
+}
+
+func (c *fakeCaster) port() int {
+	return c.ln.Addr().(*net.TCPAddr).Port
+}
+
+func (c *fakeCaster) serve() {
+	defer c.wg.Done()
+	for {
+		conn, err := c.ln.Accept()
+		if err != nil {
+			return
+		}
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.handle(conn)
+		}()
+	}
+}
+
+func (c *fakeCaster) handle(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	request, err := reader.ReadString('\n')
+	if err != nil {
+		return
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		if line == "\r\n" {
+			break
+		}
+	}
+
+	role := "dest"
+	if strings.HasPrefix(request, "GET ") {
+		role = "source"
+	}
+	c.mu.Lock()
+	c.conns[role] = append(c.conns[role], conn)
+	c.mu.Unlock()
+
+	if role == "dest" {
+		conn.Write([]byte("HTTP/1.1 200 OK\r\n\r\n"))
+		io.Copy(io.Discard, reader)
+		return
+	}
+
+	conn.Write([]byte("ICY 200 OK\r\n\r\n"))
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		io.Copy(io.Discard, reader) // NMEA từ worker
+	}()
+	frame := testFrame(1077)
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := conn.Write(frame); err != nil {
+			return
+		}
+	}
+}
+
+// kill - Đóng mọi kết nối của 1 phía (giả lập caster ngắt)
+func (c *fakeCaster) kill(role string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, conn := range c.conns[role] {
+		conn.Close()
+	}
+	c.conns[role] = nil
+}
+
+func (c *fakeCaster) close() {
+	c.ln.Close()
+	c.kill("source")
+	c.kill("dest")
+	c.wg.Wait()
+}
+
+// testFrame - Frame RTCM3 hợp lệ (đúng CRC-24Q) với message type chỉ định
+func testFrame(msgType int) []byte {
+	payload := make([]byte, 20)
+	payload[0] = byte(msgType >> 4)
+	payload[1] = byte(msgType << 4)
+	frame := append([]byte{0xD3, 0, byte(len(payload))}, payload...)
+	crc := crc24q(frame)
+	return append(frame, byte(crc>>16), byte(crc>>8), byte(crc))
+}
+
+func testStation(id string, port int) ConfigStation {
+	return ConfigStation{
+		ID: id, Enable: true,
+		SrcHost: "127.0.0.1", SrcPort: port, SrcMount: "SRC", SrcUser: "u", SrcPass: "p",
+		DstHost: "127.0.0.1", DstPort: port, DstMount: "DST", DstUser: "u", DstPass: "p",
+		Lat: 21, Lon: 105,
+	}
+}
+
+// useTempEventLog - Ghi sự kiện vào thư mục tạm thay vì events/ của repo
+func useTempEventLog(t testing.TB) {
+	old := stationEvents
+	stationEvents = &eventLog{dir: t.TempDir(), rings: make(map[string]*eventRing)}
+	t.Cleanup(func() { stationEvents = old })
+}
+
+func waitFor(t testing.TB, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(15 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// waitGoroutines - Chờ số goroutine trở về mức ban đầu, lỗi thì in stack của mọi goroutine
+func waitGoroutines(t testing.TB, base int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for runtime.NumGoroutine() > base {
+		if time.Now().After(deadline) {
+			buf := make([]byte, 1<<20)
+			buf = buf[:runtime.Stack(buf, true)]
+			t.Fatalf("%d goroutines left (started with %d):\n%s", runtime.NumGoroutine(), base, buf)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRunSessionLeavesNoGoroutines(t *testing.T) {
+	cases := []struct {
+		name string
+		end  func(c *fakeCaster, w *Worker)
+	}{
+		{"source closed", func(c *fakeCaster, w *Worker) { c.kill("source") }},
+		{"dest closed", func(c *fakeCaster, w *Worker) { c.kill("dest") }},
+		{"worker stopped", func(c *fakeCaster, w *Worker) { w.cancel() }},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			useTempEventLog(t)
+			base := runtime.NumGoroutine()
+
+			c := newFakeCaster(t)
+			w := newWorker(testStation("LEAK", c.port()), "", 0)
+			done := make(chan error, 1)
+			go func() { done <- w.runSession() }()
+
+			waitFor(t, "frames forwarded", func() bool {
+				return w.state() == StateRunning && atomic.LoadInt64(&w.counters.FramesForwarded) > 0
+			})
+			tc.end(c, w)
+			select {
+			case err := <-done:
+				t.Logf("session ended: %v", err)
+			case <-time.After(5 * time.Second):
+				t.Fatal("runSession did not return")
+			}
+
+			w.cancel()
+			c.close()
+			waitGoroutines(t, base)
+		})
+	}
+}
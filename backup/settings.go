@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+)
+
+// ================= SYSTEM SETTINGS =================
+// config.json chỉ chứa danh sách station. Các tuỳ chọn toàn hệ thống (caster nội bộ, giới hạn dial...)
+// nằm trong settings.json, không bắt buộc: thiếu file thì dùng giá trị mặc định.
+const SettingsFile = "settings.json"
+
+type CasterUser struct {
+	User   string   `json:"user"`
+	Pass   string   `json:"pass"`
+	Mounts []string `json:"mounts,omitempty"` // Rỗng = được truy cập mọi mountpoint
+}
+
+type CasterSettings struct {
+	Enable     bool         `json:"enable"`
+	Listen     string       `json:"listen"`     // VD: ":2102" (tách biệt với Monitor :8081)
+	Identifier string       `json:"identifier"` // Tên caster hiển thị trong sourcetable
+	Operator   string       `json:"operator"`
+	Country    string       `json:"country"`
+	Users      []CasterUser `json:"users"` // Rỗng = không yêu cầu đăng nhập
+}
+
+type SystemSettings struct {
+	Caster  CasterSettings  `json:"caster"`
+	Dial    DialSettings    `json:"dial"`
+	History HistorySettings `json:"history"`
+}
+
+func defaultSettings() SystemSettings {
+	return SystemSettings{
+		Caster: CasterSettings{
+			Enable:     false,
+			Listen:     ":2102",
+			Identifier: "NTRIP Relay",
+			Operator:   "relayrtcm",
+			Country:    "VNM",
+		},
+		Dial: DialSettings{
+			MaxConcurrent: DefaultMaxConcurrentDials,
+			PerSecond:     DefaultDialsPerSecond,
+		},
+		History: HistorySettings{
+			Enable:        true,
+			Dir:           DefaultHistoryDir,
+			IntervalS:     DefaultHistoryInterval,
+			RetentionDays: DefaultHistoryRetention,
+			RawDays:       DefaultHistoryRawDays,
+			DownsampleS:   DefaultHistoryDownsample,
+		},
+	}
+}
+
+// loadSettings - Đọc settings.json, các trường không khai báo giữ giá trị mặc định
+func loadSettings() SystemSettings {
+	settings := defaultSettings()
+	file, err := os.ReadFile(SettingsFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("[System] Read settings failed: %v. Using defaults", err)
+		}
+		return settings
+	}
+	if err := json.Unmarshal(file, &settings); err != nil {
+		log.Printf("[System] Settings JSON parse failed: %v. Using defaults", err)
+		return defaultSettings()
+	}
+	return settings
+}
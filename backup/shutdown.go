@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// ================= GRACEFUL SHUTDOWN =================
+// SIGINT/SIGTERM: ngừng nhận API ghi, dừng mọi worker (đóng kết nối Source/Dest) trong
+// ShutdownTimeout, ghi status cuối cùng ra StateFile rồi tắt Web Monitor bằng Shutdown(ctx).
+// Tín hiệu thứ 2 trong lúc drain -> thoát ngay theo mặc định của Go.
+const (
+	ShutdownTimeout = 20 * time.Second
+	StateFile       = "state.json" // Status cuối cùng của các station khi tắt
+
+	ExitDrained      = 0 // Mọi worker và HTTP server dừng kịp thời
+	ExitDrainTimeout = 2 // Hết ShutdownTimeout mà vẫn còn worker/HTTP request chưa xong
+)
+
+var shuttingDown atomic.Bool
+
+var monitorServer = &http.Server{Addr: MonitorPort}
+
+type finalState struct {
+	Time     time.Time       `json:"time"`
+	Drained  bool            `json:"drained"`
+	TimedOut []string        `json:"timed_out,omitempty"` // Worker chưa dừng kịp
+	Stations []StationStatus `json:"stations"`
+}
+
+// shutdown - Drain toàn hệ thống, trả về exit code
+func shutdown() int {
+	shuttingDown.Store(true)
+	start := time.Now()
+	log.Printf("[System] Shutting down (timeout %v)...", ShutdownTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+	defer cancel()
+
+	// Không cho reload chen vào lúc đang drain
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	manager.mu.Lock()
+	workers := make([]*Worker, 0, len(manager.workers))
+	for _, w := range manager.workers {
+		w.cancel()
+		workers = append(workers, w)
+	}
+	manager.mu.Unlock()
+
+	if caster != nil {
+		caster.close()
+	}
+
+	deadline, _ := ctx.Deadline()
+	stopped, timedOut := stopWorkers(workers, time.Until(deadline))
+	log.Printf("[System] Workers stopped: %d/%d", len(stopped), len(workers))
+	for _, id := range timedOut {
+		log.Printf("[%s] ⚠️  Did not stop within %v", id, ShutdownTimeout)
+	}
+
+	// Mẫu lịch sử cuối cùng: phần bộ đếm từ lần lấy mẫu trước tới lúc dừng
+	if history != nil {
+		history.sample(time.Now())
+	}
+
+	if err := saveFinalState(workers, timedOut); err != nil {
+		log.Printf("[System] ❌ Save %s failed: %v", StateFile, err)
+	}
+
+	drained := len(timedOut) == 0
+	if err := monitorServer.Shutdown(ctx); err != nil {
+		log.Printf("[System] ⚠️  Web monitor shutdown: %v", err)
+		drained = false
+	}
+
+	log.Printf("[System] Shutdown finished in %s (drained: %v)", time.Since(start).Round(time.Millisecond), drained)
+	os.Stdout.Sync()
+	os.Stderr.Sync()
+	if !drained {
+		return ExitDrainTimeout
+	}
+	return ExitDrained
+}
+
+// saveFinalState - Ghi snapshot status (bộ đếm, trạng thái, lỗi cuối) ra StateFile (ghi file tạm rồi rename)
+func saveFinalState(workers []*Worker, timedOut []string) error {
+	state := finalState{
+		Time:     time.Now(),
+		Drained:  len(timedOut) == 0,
+		TimedOut: timedOut,
+		Stations: make([]StationStatus, 0, len(workers)),
+	}
+	for _, w := range workers {
+		s := w.statusSnapshot()
+		s.MessageTypes = w.msgStats.snapshot(state.Time)
+		state.Stations = append(state.Stations, s)
+	}
+	sort.Slice(state.Stations, func(i, j int) bool { return state.Stations[i].Order < state.Stations[j].Order })
+
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := StateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, StateFile)
+}
+
+// rejectWhileShuttingDown - Đang tắt: chỉ cho phép đọc (GET), API ghi trả 503
+func rejectWhileShuttingDown(w http.ResponseWriter, r *http.Request) bool {
+	if !shuttingDown.Load() || r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return false
+	}
+	http.Error(w, "Server is shutting down", http.StatusServiceUnavailable)
+	return true
+}
+
+// serveMonitor - ListenAndServe, lỗi khác ErrServerClosed (VD: port bận) là lỗi khởi động
+func serveMonitor() {
+	if err := monitorServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+}
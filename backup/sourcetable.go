@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ================= SOURCETABLE CLIENT =================
+// GET / trả về danh sách dạng text, mỗi dòng 1 bản ghi ngăn cách bởi ';'
+// (STR = mountpoint, CAS = caster, NET = network), kết thúc bằng dòng ENDSOURCETABLE.
+const (
+	SourcetableTimeout = 15 * time.Second
+	MaxSourcetableSize = 4 * 1024 * 1024 // Caster lớn (RTK2go) có vài nghìn mountpoint
+)
+
+type SourcetableStream struct {
+	Mountpoint     string  `json:"mountpoint"`
+	Identifier     string  `json:"identifier"`
+	Format         string  `json:"format"`
+	FormatDetails  string  `json:"format_details"`
+	Carrier        int     `json:"carrier"`
+	NavSystem      string  `json:"nav_system"`
+	Network        string  `json:"network"`
+	Country        string  `json:"country"`
+	Lat            float64 `json:"lat"`
+	Lon            float64 `json:"lon"`
+	NMEA           bool    `json:"nmea"`     // Mountpoint cần client gửi GGA
+	Solution       int     `json:"solution"` // 0 = single base, 1 = network (VRS)
+	Generator      string  `json:"generator"`
+	Compression    string  `json:"compression"`
+	Authentication string  `json:"authentication"` // N / B / D
+	Fee            bool    `json:"fee"`
+	Bitrate        int     `json:"bitrate"`
+	Misc           string  `json:"misc"`
+}
+
+type SourcetableCaster struct {
+	Host         string  `json:"host"`
+	Port         int     `json:"port"`
+	Identifier   string  `json:"identifier"`
+	Operator     string  `json:"operator"`
+	NMEA         bool    `json:"nmea"`
+	Country      string  `json:"country"`
+	Lat          float64 `json:"lat"`
+	Lon          float64 `json:"lon"`
+	FallbackHost string  `json:"fallback_host"`
+	FallbackPort int     `json:"fallback_port"`
+	Misc         string  `json:"misc"`
+}
+
+type SourcetableNetwork struct {
+	Identifier     string `json:"identifier"`
+	Operator       string `json:"operator"`
+	Authentication string `json:"authentication"`
+	Fee            bool   `json:"fee"`
+	WebNet         string `json:"web_net"`
+	WebStr         string `json:"web_str"`
+	WebReg         string `json:"web_reg"`
+	Misc           string `json:"misc"`
+}
+
+type Sourcetable struct {
+	Server   string               `json:"server,omitempty"` // Header Server của caster (nếu có)
+	Streams  []SourcetableStream  `json:"streams"`
+	Casters  []SourcetableCaster  `json:"casters"`
+	Networks []SourcetableNetwork `json:"networks"`
+}
+
+// fetchSourcetable - Kết nối caster, gửi GET / và parse sourcetable
+func fetchSourcetable(ctx context.Context, host string, port int, user, pass, proxyURL string, useSSL bool) (*Sourcetable, error) {
+	ctx, cancel := context.WithTimeout(ctx, SourcetableTimeout)
+	defer cancel()
+
+	conn, err := connectToHost(ctx, host, port, proxyURL, useSSL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("dial caster: %w", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(SourcetableTimeout))
+
+	// Dùng User-Agent của 1 device profile ngẫu nhiên như các worker
+	rng := rand.New(rand.NewSource(time.Now().UnixNano()))
+	userAgent := generateUserAgent(deviceProfiles[rng.Intn(len(deviceProfiles))], rng)
+	req := fmt.Sprintf("GET / HTTP/1.1\r\nHost: %s\r\nNtrip-Version: Ntrip/2.0\r\nUser-Agent: NTRIP %s\r\n", host, userAgent)
+	if user != "" {
+		req += "Authorization: Basic " + basicAuth(user, pass) + "\r\n"
+	}
+	req += "Connection: close\r\n\r\n"
+	if _, err := conn.Write([]byte(req)); err != nil {
+		return nil, fmt.Errorf("send request: %w", err)
+	}
+
+	reader := bufio.NewReaderSize(conn, BufferSize)
+	resp, err := readResponse(reader, conn)
+	if err != nil {
+		return nil, err
+	}
+	// NTRIP 1.0: "SOURCETABLE 200 OK", NTRIP 2.0: "HTTP/1.1 200 OK" + Content-Type: gnss/sourcetable
+	if !resp.sourcetable() && !resp.ok() {
+		return nil, &rejectedError{Code: resp.Code, StatusLine: resp.StatusLine}
+	}
+	conn.SetDeadline(time.Now().Add(SourcetableTimeout))
+
+	table, err := parseSourcetable(io.LimitReader(sourceBodyReader(reader, resp), MaxSourcetableSize))
+	if err != nil {
+		return nil, err
+	}
+	table.Server = resp.Header.Get("Server")
+	return table, nil
+}
+
+// parseSourcetable - Parse các bản ghi STR/CAS/NET, bỏ qua dòng không hợp lệ
+func parseSourcetable(r io.Reader) (*Sourcetable, error) {
+	table := &Sourcetable{
+		Streams:  []SourcetableStream{},
+		Casters:  []SourcetableCaster{},
+		Networks: []SourcetableNetwork{},
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), 64*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "ENDSOURCETABLE" {
+			return table, nil
+		}
+		fields := strings.Split(line, ";")
+		switch fields[0] {
+		case "STR":
+			f := padFields(fields, 19)
+			table.Streams = append(table.Streams, SourcetableStream{
+				Mountpoint:     f[1],
+				Identifier:     f[2],
+				Format:         f[3],
+				FormatDetails:  f[4],
+				Carrier:        atoiField(f[5]),
+				NavSystem:      f[6],
+				Network:        f[7],
+				Country:        f[8],
+				Lat:            atofField(f[9]),
+				Lon:            atofField(f[10]),
+				NMEA:           f[11] == "1",
+				Solution:       atoiField(f[12]),
+				Generator:      f[13],
+				Compression:    f[14],
+				Authentication: f[15],
+				Fee:            f[16] == "Y",
+				Bitrate:        atoiField(f[17]),
+				Misc:           strings.Join(fields[min(18, len(fields)):], ";"),
+			})
+		case "CAS":
+			f := padFields(fields, 12)
+			table.Casters = append(table.Casters, SourcetableCaster{
+				Host:         f[1],
+				Port:         atoiField(f[2]),
+				Identifier:   f[3],
+				Operator:     f[4],
+				NMEA:         f[5] == "1",
+				Country:      f[6],
+				Lat:          atofField(f[7]),
+				Lon:          atofField(f[8]),
+				FallbackHost: f[9],
+				FallbackPort: atoiField(f[10]),
+				Misc:         strings.Join(fields[min(11, len(fields)):], ";"),
+			})
+		case "NET":
+			f := padFields(fields, 9)
+			table.Networks = append(table.Networks, SourcetableNetwork{
+				Identifier:     f[1],
+				Operator:       f[2],
+				Authentication: f[3],
+				Fee:            f[4] == "Y",
+				WebNet:         f[5],
+				WebStr:         f[6],
+				WebReg:         f[7],
+				Misc:           strings.Join(fields[min(8, len(fields)):], ";"),
+			})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read sourcetable: %w", err)
+	}
+	// Thiếu ENDSOURCETABLE (caster đóng kết nối sớm): vẫn trả về những gì đã đọc được
+	return table, nil
+}
+
+// padFields - Caster hay bỏ bớt cột cuối, thêm chuỗi rỗng cho đủ số cột
+func padFields(fields []string, n int) []string {
+	for len(fields) < n {
+		fields = append(fields, "")
+	}
+	return fields
+}
+
+func atoiField(s string) int {
+	v, _ := strconv.Atoi(strings.TrimSpace(s))
+	return v
+}
+
+func atofField(s string) float64 {
+	v, _ := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	return v
+}
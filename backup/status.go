@@ -0,0 +1,128 @@
+package main
+
+import (
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// ================= STATION STATUS =================
+// Worker và các luồng phụ ghi status qua updateStatus/setState (khoá statusMu của worker),
+// /status chỉ đọc bản sao qua statusSnapshot. Bộ đếm tăng liên tục nằm riêng trong
+// stationCounters và chỉ truy cập bằng atomic.
+const MaxStateHistory = 30 // Số lần chuyển trạng thái giữ lại trong status
+
+type StationState int
+
+const (
+	StateNotStarted StationState = iota
+	StateStarting
+	StateWaiting
+	StateQueued
+	StateConnectingSource
+	StateConnectingDest
+	StateRunning
+	StateError
+	StateStopped
+	StateDisabled
+	StateConfigError
+)
+
+var stateNames = [...]string{
+	StateNotStarted:       "Not Started",
+	StateStarting:         "Starting",
+	StateWaiting:          "Waiting",
+	StateQueued:           "Queued for dial",
+	StateConnectingSource: "Connecting Source",
+	StateConnectingDest:   "Connecting Dest",
+	StateRunning:          "Running",
+	StateError:            "Error",
+	StateStopped:          "Stopped",
+	StateDisabled:         "Disabled",
+	StateConfigError:      "Config Error",
+}
+
+func (s StationState) String() string {
+	if s >= 0 && int(s) < len(stateNames) {
+		return stateNames[s]
+	}
+	return "Unknown"
+}
+
+type StateChange struct {
+	Time    time.Time `json:"time"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Message string    `json:"message,omitempty"`
+}
+
+// stationCounters - Bộ đếm của worker, cộng dồn qua nhiều session (chỉ dùng atomic)
+type stationCounters struct {
+	BytesForwarded  int64
+	FramesForwarded int64
+	CRCErrors       int64
+	BytesDropped    int64
+	DestDropped     int64
+	Sessions        int64                       // Số session đã bắt đầu
+	Reconnects      [numReasons]int64           // Số session kết thúc do lỗi, theo lý do (Reason*)
+	EpochSeconds    int64                       // Số giây có ít nhất 1 message quan sát (epoch)
+	Causes          [len(errorEventTypes)]int64 // Số sự kiện lỗi theo loại (thứ tự errorEventTypes)
+}
+
+// transition - Đổi trạng thái (ghi lịch sử nếu khác trạng thái cũ), message != "" thì cập nhật LastMessage
+func (s *StationStatus) transition(state StationState, message string) {
+	if message != "" {
+		s.LastMessage = message
+	}
+	if state == s.state {
+		return
+	}
+	if s.state == StateRunning && state != StateStopped {
+		s.outages++
+	}
+	now := time.Now()
+	history := append(s.StateHistory, StateChange{Time: now, From: s.state.String(), To: state.String(), Message: message})
+	if len(history) > MaxStateHistory {
+		history = history[len(history)-MaxStateHistory:]
+	}
+	s.StateHistory = history
+	s.state = state
+	s.Status = state.String()
+	s.StateSince = now
+}
+
+// updateStatus - Sửa status dưới khoá của worker
+func (w *Worker) updateStatus(fn func(s *StationStatus)) {
+	w.statusMu.Lock()
+	defer w.statusMu.Unlock()
+	fn(w.status)
+}
+
+func (w *Worker) setState(state StationState, message string) {
+	w.updateStatus(func(s *StationStatus) {
+		s.transition(state, message)
+	})
+}
+
+func (w *Worker) state() StationState {
+	w.statusMu.Lock()
+	defer w.statusMu.Unlock()
+	return w.status.state
+}
+
+// statusSnapshot - Bản sao status tại thời điểm gọi (slice được copy, không chia sẻ với worker)
+func (w *Worker) statusSnapshot() StationStatus {
+	w.statusMu.Lock()
+	s := *w.status
+	w.statusMu.Unlock()
+
+	s.SourceSwitches = slices.Clone(s.SourceSwitches)
+	s.StateHistory = slices.Clone(s.StateHistory)
+	s.BytesForwarded = atomic.LoadInt64(&w.counters.BytesForwarded)
+	s.FramesForwarded = atomic.LoadInt64(&w.counters.FramesForwarded)
+	s.CRCErrors = atomic.LoadInt64(&w.counters.CRCErrors)
+	s.BytesDropped = atomic.LoadInt64(&w.counters.BytesDropped)
+	s.DestDropped = atomic.LoadInt64(&w.counters.DestDropped)
+	s.Uptime = time.Since(s.StartTime).Round(time.Second).String()
+	return s
+}
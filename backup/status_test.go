@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stopAllWorkers - Dừng và bỏ mọi worker của manager (dọn dẹp sau test)
+func stopAllWorkers(t testing.TB) {
+	manager.mu.Lock()
+	old := make([]*Worker, 0, len(manager.workers))
+	for id, w := range manager.workers {
+		w.cancel()
+		old = append(old, w)
+		delete(manager.workers, id)
+	}
+	manager.mu.Unlock()
+	if _, timedOut := stopWorkers(old, 10*time.Second); len(timedOut) > 0 {
+		t.Errorf("workers did not stop: %v", timedOut)
+	}
+}
+
+// TestStatusRace - 200 worker chạy với caster giả trong khi /status, /metrics, /api/stations bị gọi liên tục.
+// Chạy với go test -race để kiểm tra status/bộ đếm được truy cập an toàn.
+func TestStatusRace(t *testing.T) {
+	if testing.Short() {
+		t.Skip("starts 200 workers")
+	}
+	const stations = 200
+	t.Chdir(t.TempDir()) // config.json của test
+	useTempEventLog(t)
+	c := newFakeCaster(t)
+
+	configs := make([]ConfigStation, stations)
+	for i := range configs {
+		configs[i] = testStation(fmt.Sprintf("S%03d", i), c.port())
+	}
+	if err := saveConfigs(configs); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { stopAllWorkers(t) })
+	if res, _ := reloadConfig(true); len(res.Started) != stations {
+		t.Fatalf("started %d workers, want %d (%+v)", len(res.Started), stations, res)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", handleStatus)
+	mux.HandleFunc("/metrics", handleMetrics)
+	mux.HandleFunc("/api/stations/", handleStationItem)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	get := func(path string) ([]byte, int, error) {
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			return nil, 0, err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return body, resp.StatusCode, err
+	}
+	running := func() int {
+		body, _, err := get("/status")
+		if err != nil {
+			return 0
+		}
+		var stats []StationStatus
+		json.Unmarshal(body, &stats)
+		n := 0
+		for _, s := range stats {
+			if s.Status == StateRunning.String() {
+				n++
+			}
+		}
+		return n
+	}
+	waitFor(t, "all stations running", func() bool { return running() == stations })
+
+	// Vừa gọi API vừa làm worker đổi trạng thái: ngắt dest rồi source để session kết thúc / retry
+	paths := []string{"/status", "/metrics", "/api/stations/S000/events", "/api/stations/S199/events?limit=5", "/api/stations/S100/history"}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for n := i; ; n++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				path := paths[n%len(paths)]
+				_, code, err := get(path)
+				if err != nil || code >= 500 {
+					t.Errorf("GET %s: %d %v", path, code, err)
+					return
+				}
+			}
+		}(i)
+	}
+	time.Sleep(500 * time.Millisecond)
+	c.kill("dest")
+	time.Sleep(500 * time.Millisecond)
+	c.kill("source")
+	waitFor(t, "all sessions ended", func() bool { return running() == 0 })
+	time.Sleep(500 * time.Millisecond)
+	close(stop)
+	wg.Wait()
+}
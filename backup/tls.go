@@ -0,0 +1,164 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// ================= TLS SETTINGS =================
+// Tuỳ chọn TLS riêng cho từng kết nối (src_tls, dst_tls, tls của source dự phòng).
+// Không khai báo = xác thực theo CA hệ thống với SNI là host như trước.
+const DefaultCertExpiryWarnDays = 14 // Cảnh báo khi chứng chỉ caster còn hạn ít hơn N ngày
+
+type TLSSettings struct {
+	CAFile         string `json:"ca_file,omitempty"`          // CA bundle (PEM) thay cho CA hệ thống
+	CertFile       string `json:"cert_file,omitempty"`        // Client certificate (mutual TLS)
+	KeyFile        string `json:"key_file,omitempty"`         // Client private key
+	ServerName     string `json:"sni,omitempty"`              // SNI / tên kiểm tra chứng chỉ, mặc định là host
+	MinVersion     string `json:"min_version,omitempty"`      // "1.0", "1.1", "1.2" (mặc định), "1.3"
+	PinSHA256      string `json:"pin_sha256,omitempty"`       // SHA-256 của public key (SPKI) chứng chỉ caster, hex hoặc base64
+	ExpiryWarnDays int    `json:"expiry_warn_days,omitempty"` // Mặc định 14 ngày
+}
+
+// TLSInfo - Thông tin phiên TLS đã bắt tay, hiển thị trong status
+type TLSInfo struct {
+	Version    string    `json:"version"`
+	Cipher     string    `json:"cipher"`
+	ServerName string    `json:"server_name"`
+	Subject    string    `json:"subject"`
+	Issuer     string    `json:"issuer"`
+	NotAfter   time.Time `json:"not_after"`
+	DaysLeft   int       `json:"days_left"`
+	Expiring   bool      `json:"expiring"` // Còn hạn ít hơn expiry_warn_days
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// tlsConfig - Dựng tls.Config cho host. s == nil -> cấu hình mặc định.
+func (s *TLSSettings) tlsConfig(host string) (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName: host,
+		MinVersion: tls.VersionTLS12,
+	}
+	if s == nil {
+		return cfg, nil
+	}
+
+	if s.ServerName != "" {
+		cfg.ServerName = s.ServerName
+	}
+	if s.MinVersion != "" {
+		v, ok := tlsVersions[s.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid min_version %q (use 1.0, 1.1, 1.2 or 1.3)", s.MinVersion)
+		}
+		cfg.MinVersion = v
+	}
+	if s.CAFile != "" {
+		pem, err := os.ReadFile(s.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_file %s: no PEM certificates found", s.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if s.CertFile != "" || s.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(s.CertFile, s.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	if s.PinSHA256 != "" {
+		pin, err := decodePin(s.PinSHA256)
+		if err != nil {
+			return nil, err
+		}
+		// Kiểm tra pin sau khi xác thực chuỗi chứng chỉ (không thay thế việc xác thực)
+		cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+			if len(cs.PeerCertificates) == 0 {
+				return errors.New("no peer certificate to check pin")
+			}
+			sum := sha256.Sum256(cs.PeerCertificates[0].RawSubjectPublicKeyInfo)
+			if string(sum[:]) != string(pin) {
+				return fmt.Errorf("certificate pin mismatch: got sha256/%s", base64.StdEncoding.EncodeToString(sum[:]))
+			}
+			return nil
+		}
+	}
+	return cfg, nil
+}
+
+func (s *TLSSettings) expiryWarnDays() int {
+	if s != nil && s.ExpiryWarnDays > 0 {
+		return s.ExpiryWarnDays
+	}
+	return DefaultCertExpiryWarnDays
+}
+
+// decodePin - Nhận "sha256/<base64>", base64 hoặc hex (cho phép dấu ':')
+func decodePin(pin string) ([]byte, error) {
+	pin = strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")
+	if b, err := hex.DecodeString(strings.ReplaceAll(pin, ":", "")); err == nil && len(b) == sha256.Size {
+		return b, nil
+	}
+	if b, err := base64.StdEncoding.DecodeString(pin); err == nil && len(b) == sha256.Size {
+		return b, nil
+	}
+	return nil, fmt.Errorf("invalid pin_sha256 %q: want 32-byte hex or base64", pin)
+}
+
+// connTLSInfo - Thông tin phiên TLS của conn (nil nếu không phải TLS)
+func connTLSInfo(conn net.Conn, s *TLSSettings) *TLSInfo {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return nil
+	}
+	cs := tlsConn.ConnectionState()
+	info := &TLSInfo{
+		Version:    tls.VersionName(cs.Version),
+		Cipher:     tls.CipherSuiteName(cs.CipherSuite),
+		ServerName: cs.ServerName,
+	}
+	if len(cs.PeerCertificates) > 0 {
+		leaf := cs.PeerCertificates[0]
+		info.Subject = leaf.Subject.CommonName
+		info.Issuer = leaf.Issuer.CommonName
+		info.NotAfter = leaf.NotAfter
+		info.DaysLeft = int(time.Until(leaf.NotAfter).Hours() / 24)
+		info.Expiring = info.DaysLeft < s.expiryWarnDays()
+	}
+	return info
+}
+
+// certWarning - Cảnh báo chứng chỉ sắp hết hạn của Source/Dest ("" nếu không có)
+func certWarning(src, dst *TLSInfo) string {
+	var warnings []string
+	for _, t := range []struct {
+		label string
+		info  *TLSInfo
+	}{{"Source", src}, {"Dest", dst}} {
+		if t.info != nil && t.info.Expiring {
+			warnings = append(warnings, fmt.Sprintf("%s certificate expires in %d days (%s)",
+				t.label, t.info.DaysLeft, t.info.NotAfter.Format("2006-01-02")))
+		}
+	}
+	return strings.Join(warnings, "; ")
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecodePin(t *testing.T) {
+	sum := sha256.Sum256([]byte("spki"))
+	hexPin := hex.EncodeToString(sum[:])
+	var colons []string
+	for _, b := range sum {
+		colons = append(colons, strings.ToUpper(hex.EncodeToString([]byte{b})))
+	}
+	b64 := base64.StdEncoding.EncodeToString(sum[:])
+
+	for _, tc := range []struct {
+		name    string
+		pin     string
+		wantErr bool
+	}{
+		{"hex", hexPin, false},
+		{"hex with colons", strings.Join(colons, ":"), false},
+		{"base64", b64, false},
+		{"sha256/ prefix", " sha256/" + b64 + " ", false},
+		{"hex too short", hexPin[:62], true},
+		{"hex too long", hexPin + "00", true},
+		{"base64 of 20 bytes", base64.StdEncoding.EncodeToString(sum[:20]), true},
+		{"garbage", "not-a-pin", true},
+		{"empty", "", true},
+	} {
+		got, err := decodePin(tc.pin)
+		if tc.wantErr {
+			if err == nil {
+				t.Errorf("%s: decodePin(%q) = %x, want error", tc.name, tc.pin, got)
+			}
+			continue
+		}
+		if err != nil || string(got) != string(sum[:]) {
+			t.Errorf("%s: decodePin(%q) = %x, %v", tc.name, tc.pin, got, err)
+		}
+	}
+}
+
+func TestTLSConfigSettings(t *testing.T) {
+	cfg, err := (*TLSSettings)(nil).tlsConfig("caster.example")
+	if err != nil || cfg.ServerName != "caster.example" || cfg.MinVersion != tls.VersionTLS12 {
+		t.Errorf("default config = %+v, %v", cfg, err)
+	}
+
+	cfg, err = (&TLSSettings{ServerName: "sni.example", MinVersion: "1.3"}).tlsConfig("caster.example")
+	if err != nil || cfg.ServerName != "sni.example" || cfg.MinVersion != tls.VersionTLS13 {
+		t.Errorf("sni/min_version = %+v, %v", cfg, err)
+	}
+
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.txt")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0644)
+	for name, s := range map[string]*TLSSettings{
+		"bad min_version":  {MinVersion: "1.4"},
+		"missing ca_file":  {CAFile: filepath.Join(dir, "missing.pem")},
+		"ca_file no PEM":   {CAFile: notPEM},
+		"key without cert": {KeyFile: notPEM},
+		"bad pin":          {PinSHA256: "abcd"},
+	} {
+		if _, err := s.tlsConfig("caster.example"); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+// TestTLSConfigPin - Bắt tay thật với server httptest: CA riêng qua ca_file, pin đúng/sai
+func TestTLSConfigPin(t *testing.T) {
+	srv := httptest.NewUnstartedServer(nil)
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0) // Bắt tay bị từ chối là chủ ý
+	srv.StartTLS()
+	defer srv.Close()
+	cert := srv.Certificate()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	other := sha256.Sum256([]byte("other key"))
+	addr := srv.Listener.Addr().String()
+
+	for _, tc := range []struct {
+		name    string
+		s       *TLSSettings
+		wantErr string
+	}{
+		{"ca_file", &TLSSettings{CAFile: caFile}, ""},
+		{"pin match", &TLSSettings{CAFile: caFile, PinSHA256: "sha256/" + base64.StdEncoding.EncodeToString(sum[:])}, ""},
+		{"pin mismatch", &TLSSettings{CAFile: caFile, PinSHA256: hex.EncodeToString(other[:])}, "pin mismatch"},
+		{"system CA", nil, "certificate"}, // Pin không thay thế việc xác thực chuỗi chứng chỉ
+		{"pin without CA", &TLSSettings{PinSHA256: hex.EncodeToString(sum[:])}, "certificate"},
+	} {
+		cfg, err := tc.s.tlsConfig("example.com") // Chứng chỉ httptest cấp cho example.com
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		conn, err := tls.Dial("tcp", addr, cfg)
+		if err == nil {
+			conn.Close()
+		}
+		switch {
+		case tc.wantErr == "" && err != nil:
+			t.Errorf("%s: handshake failed: %v", tc.name, err)
+		case tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)):
+			t.Errorf("%s: err = %v, want %q", tc.name, err, tc.wantErr)
+		}
+	}
+}